@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/transcovo/foreachrepo/github"
+	"log"
+	"os"
+	"strings"
+)
+
+const ABANDON_EXAMPLES = `. Examples:
+
+Preview what abandoning a campaign would do:
+
+$> foreachrepo abandon -org transcovo -campaign freeze-all-deps -dry-run
+
+Close the campaign pull requests with a comment and delete their branches, without confirmation:
+
+$> foreachrepo abandon -org transcovo -campaign freeze-all-deps -comment "Wrong versions, sorry" -yes`
+
+func confirm(question string) bool {
+	fmt.Print(question, " [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func abandonCommand(args []string) {
+	flags := flag.NewFlagSet("abandon", flag.ExitOnError)
+	organization := flags.String("org", "DEFAULT", "The organization to search the campaign pull requests in")
+	campaign := flags.String("campaign", "DEFAULT", "The campaign branch name")
+	reportFile := flags.String("report", "", "A saved run report listing the campaign pull requests, instead of searching them")
+	comment := flags.String("comment", "", "A comment to leave on the pull requests before closing them")
+	dryRun := flags.Bool("dry-run", false, "Only show what would be done")
+	yes := flags.Bool("yes", false, "Don't ask for confirmation")
+	flags.Parse(args)
+
+	if *campaign == "DEFAULT" && *reportFile == "" {
+		log.Fatalln("campaign or report flag required", ABANDON_EXAMPLES)
+	}
+
+	httpInterface := githubHttpInterface()
+
+	statuses, err := campaignStatuses(httpInterface, *organization, *campaign, *reportFile)
+	if err != nil {
+		log.Fatalln("Could not list the campaign pull requests:", err, ABANDON_EXAMPLES)
+	}
+
+	println("===== Plan =====")
+	for _, status := range statuses {
+		if status.State == "open" {
+			println("close", status.Url)
+		}
+		if !status.Merged {
+			println("delete branch", status.Branch, "of", status.Repo)
+		}
+	}
+	if *dryRun || len(statuses) == 0 {
+		return
+	}
+	if !*yes && !confirm("Proceed?") {
+		log.Fatalln("Aborted")
+	}
+
+	done := []string{}
+	for _, status := range statuses {
+		if status.State == "open" {
+			if err := github.ClosePullRequest(httpInterface, status, *comment); err != nil {
+				log.Println(status.Repo, " -> failed to close: ", err.Error())
+				continue
+			}
+			done = append(done, "closed " + status.Url)
+		}
+		if !status.Merged {
+			err := github.DeleteBranch(httpInterface, status)
+			if apiErr, ok := err.(*github.ApiError); ok && apiErr.StatusCode == 422 {
+				done = append(done, "branch " + status.Branch + " of " + status.Repo + " was already deleted")
+			} else if err != nil {
+				log.Println(status.Repo, " -> failed to delete the branch: ", err.Error())
+			} else {
+				done = append(done, "deleted branch " + status.Branch + " of " + status.Repo)
+			}
+		}
+	}
+	println("===== Done =====")
+	println(strings.Join(done, "\n"))
+}
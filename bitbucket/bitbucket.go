@@ -0,0 +1,174 @@
+package bitbucket
+
+import (
+	"errors"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/httpapi"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const PROVIDER = "bitbucket"
+
+// Client calls the REST API of a Bitbucket Server (or Data Center) instance, authenticated with an HTTP access token
+type Client struct {
+	httpapi.Client
+}
+
+func NewClient(baseUrl string, token string) *Client {
+	return &Client{httpapi.Client{Provider: "Bitbucket", BaseUrl: baseUrl, AuthHeader: "Authorization", AuthValue: "Bearer " + token}}
+}
+
+func (C *Client) apiUrl(path string) string {
+	return C.Url("rest/api/1.0/" + path)
+}
+
+type bitbucketLink struct {
+	Href string
+	Name string
+}
+
+type bitbucketRepo struct {
+	Slug    string
+	Name    string
+	Project struct {
+		Key string
+	}
+	Links   struct {
+		Clone []bitbucketLink
+	}
+}
+
+type bitbucketReposPage struct {
+	Values        []bitbucketRepo
+	IsLastPage    bool
+	NextPageStart int
+}
+
+func (C *Client) toRepo(repository bitbucketRepo) github.Repo {
+	apiUrl := C.apiUrl("projects/" + url.PathEscape(repository.Project.Key) + "/repos/" + url.PathEscape(repository.Slug))
+	repo := github.Repo{
+		Name: repository.Slug,
+		FullName: C.Host() + "/" + repository.Project.Key + "/" + repository.Slug,
+		PullsUrl: apiUrl + "/pull-requests",
+		ApiUrl: apiUrl,
+		Provider: PROVIDER,
+	}
+	for _, link := range repository.Links.Clone {
+		if link.Name == "ssh" {
+			repo.GitUrl = link.Href
+		}
+		if link.Name == "http" {
+			repo.HttpsUrl = link.Href
+		}
+	}
+	return repo
+}
+
+// GetProjectRepos lists the repos of a project, given by its key
+func (C *Client) GetProjectRepos(project string) ([]github.Repo, error) {
+	repos := []github.Repo{}
+	start := 0
+	for i := 1; ; i++ {
+		log.Print("Loading page ", i)
+
+		query := url.Values{}
+		query.Set("start", strconv.Itoa(start))
+		query.Set("limit", "100")
+		page := &bitbucketReposPage{}
+		_, err := C.Call("GET", C.apiUrl("projects/" + url.PathEscape(project) + "/repos?" + query.Encode()), nil, page)
+		if err != nil {
+			return nil, err
+		}
+		for _, repository := range page.Values {
+			repos = append(repos, C.toRepo(repository))
+		}
+		if page.IsLastPage || len(page.Values) == 0 {
+			return repos, nil
+		}
+		start = page.NextPageStart
+	}
+}
+
+type bitbucketBranch struct {
+	Id        string
+	DisplayId string
+}
+
+func (C *Client) defaultBranch(repo github.Repo) (string, error) {
+	if repo.DefaultBranch != "" {
+		return repo.DefaultBranch, nil
+	}
+	branch := &bitbucketBranch{}
+	_, err := C.Call("GET", repo.ApiUrl + "/branches/default", nil, branch)
+	if err != nil {
+		return "", err
+	}
+	return branch.DisplayId, nil
+}
+
+// reviewers checks the reviewers exist, since a single unknown one makes the pull request creation fail
+func (C *Client) reviewers(usernames []string) ([]map[string]interface{}, []string) {
+	reviewers := []map[string]interface{}{}
+	warnings := []string{}
+	for _, username := range usernames {
+		var ignored interface{}
+		_, err := C.Call("GET", C.apiUrl("users/" + url.PathEscape(username)), nil, &ignored)
+		if err != nil {
+			warnings = append(warnings, "Could not add reviewer " + username + ": " + err.Error())
+			continue
+		}
+		reviewers = append(reviewers, map[string]interface{}{"user": map[string]string{"name": username}})
+	}
+	return reviewers, warnings
+}
+
+type bitbucketPullRequest struct {
+	Id    int
+	Links struct {
+		Self []bitbucketLink
+	}
+}
+
+// CreatePullRequest opens a pull request with the reviewers of the options. Bitbucket Server has no labels,
+// assignees, milestones or team reviewers, those options are reported as warnings.
+func (C *Client) CreatePullRequest(repo github.Repo, branch string, title string, options github.PullRequestOptions) (github.PullRequest, []string) {
+	reviewers, warnings := C.reviewers(options.Reviewers)
+	if len(options.Labels) > 0 || len(options.Assignees) > 0 || options.Milestone != "" || len(options.TeamReviewers) > 0 {
+		warnings = append(warnings, "Labels, assignees, milestones and team reviewers are not supported by Bitbucket Server")
+	}
+
+	targetBranch, err := C.defaultBranch(repo)
+	if err != nil {
+		panic(err)
+	}
+	project := strings.Split(strings.TrimPrefix(repo.FullName, C.Host() + "/"), "/")[0]
+	repository := map[string]interface{}{"slug": repo.Name, "project": map[string]string{"key": project}}
+	input := map[string]interface{}{
+		"title": title,
+		"description": "Generated by foreachrepo",
+		"fromRef": map[string]interface{}{"id": "refs/heads/" + branch, "repository": repository},
+		"toRef": map[string]interface{}{"id": "refs/heads/" + targetBranch, "repository": repository},
+		"reviewers": reviewers,
+	}
+	if options.Draft {
+		input["draft"] = true
+	}
+
+	result := &bitbucketPullRequest{}
+	_, err = C.Call("POST", repo.PullsUrl, input, result)
+	if err != nil {
+		panic(err)
+	}
+	if len(result.Links.Self) == 0 {
+		panic(errors.New("No link returned for the pull request of " + repo.FullName))
+	}
+	pull := github.PullRequest{
+		Number: result.Id,
+		Url: result.Links.Self[0].Href,
+		ApiUrl: repo.PullsUrl + "/" + strconv.Itoa(result.Id),
+	}
+	return pull, warnings
+}
@@ -0,0 +1,113 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/httpapi"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// bitbucketStandIn serves the few Bitbucket Server REST endpoints used by foreachrepo, and records the pull
+// requests created
+type bitbucketStandIn struct {
+	server       *httptest.Server
+	pullRequests []map[string]interface{}
+}
+
+func newBitbucketStandIn() *bitbucketStandIn {
+	standIn := &bitbucketStandIn{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rest/api/1.0/projects/TOOLS/repos", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(401)
+			fmt.Fprint(w, `{"errors": [{"message": "Authentication failed"}]}`)
+			return
+		}
+		if r.URL.Query().Get("start") == "0" {
+			fmt.Fprint(w, `{"isLastPage": false, "nextPageStart": 1, "values": [{"slug": "cli", "name": "CLI",
+				"project": {"key": "TOOLS"}, "links": {"clone": [
+					{"href": "https://bitbucket.example.com/scm/tools/cli.git", "name": "http"},
+					{"href": "ssh://git@bitbucket.example.com:7999/tools/cli.git", "name": "ssh"}]}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"isLastPage": true, "values": [{"slug": "web", "name": "Web", "project": {"key": "TOOLS"},
+			"links": {"clone": [{"href": "ssh://git@bitbucket.example.com:7999/tools/web.git", "name": "ssh"}]}}]}`)
+	})
+	mux.HandleFunc("/rest/api/1.0/projects/TOOLS/repos/cli/branches/default", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": "refs/heads/develop", "displayId": "develop"}`)
+	})
+	mux.HandleFunc("/rest/api/1.0/users/alice", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name": "alice", "id": 11}`)
+	})
+	mux.HandleFunc("/rest/api/1.0/users/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+		fmt.Fprint(w, `{"errors": [{"message": "User does not exist"}]}`)
+	})
+	mux.HandleFunc("/rest/api/1.0/projects/TOOLS/repos/cli/pull-requests", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		pullRequest := map[string]interface{}{}
+		json.Unmarshal(body, &pullRequest)
+		standIn.pullRequests = append(standIn.pullRequests, pullRequest)
+		w.WriteHeader(201)
+		fmt.Fprint(w, `{"id": 3, "links": {"self": [{"href": "https://bitbucket.example.com/projects/TOOLS/repos/cli/pull-requests/3"}]}}`)
+	})
+	standIn.server = httptest.NewServer(mux)
+	return standIn
+}
+
+func TestGetProjectRepos(t *testing.T) {
+	standIn := newBitbucketStandIn()
+	defer standIn.server.Close()
+	client := NewClient(standIn.server.URL, "secret")
+
+	repos, err := client.GetProjectRepos("TOOLS")
+	assert.Nil(t, err)
+	assert.Len(t, repos, 2)
+	assert.Equal(t, "cli", repos[0].Name)
+	assert.Equal(t, client.Host() + "/TOOLS/cli", repos[0].FullName)
+	assert.Equal(t, "ssh://git@bitbucket.example.com:7999/tools/cli.git", repos[0].GitUrl)
+	assert.Equal(t, standIn.server.URL + "/rest/api/1.0/projects/TOOLS/repos/cli/pull-requests", repos[0].PullsUrl)
+	assert.Equal(t, PROVIDER, repos[0].Provider)
+	assert.Equal(t, "web", repos[1].Name)
+}
+
+func TestGetProjectReposUnauthorized(t *testing.T) {
+	standIn := newBitbucketStandIn()
+	defer standIn.server.Close()
+	client := NewClient(standIn.server.URL, "wrong")
+
+	repos, err := client.GetProjectRepos("TOOLS")
+	assert.Nil(t, repos)
+	assert.Equal(t, 401, err.(*httpapi.ApiError).StatusCode)
+}
+
+func TestCreatePullRequest(t *testing.T) {
+	standIn := newBitbucketStandIn()
+	defer standIn.server.Close()
+	client := NewClient(standIn.server.URL, "secret")
+	repos, _ := client.GetProjectRepos("TOOLS")
+	options := github.PullRequestOptions{Reviewers: []string{"alice", "nobody"}, Labels: []string{"tech"}}
+
+	pull, warnings := client.CreatePullRequest(repos[0], "freeze-all-deps", "TECH Freeze all dependencies", options)
+	assert.Equal(t, 3, pull.Number)
+	assert.Equal(t, "https://bitbucket.example.com/projects/TOOLS/repos/cli/pull-requests/3", pull.Url)
+	assert.Equal(t, standIn.server.URL + "/rest/api/1.0/projects/TOOLS/repos/cli/pull-requests/3", pull.ApiUrl)
+	assert.Len(t, warnings, 2)
+	assert.Contains(t, warnings[0], "nobody")
+	assert.Contains(t, warnings[1], "not supported")
+
+	assert.Len(t, standIn.pullRequests, 1)
+	bytes, _ := json.Marshal(standIn.pullRequests[0])
+	assert.JSONEq(t, `{
+		"title": "TECH Freeze all dependencies",
+		"description": "Generated by foreachrepo",
+		"fromRef": {"id": "refs/heads/freeze-all-deps", "repository": {"slug": "cli", "project": {"key": "TOOLS"}}},
+		"toRef": {"id": "refs/heads/develop", "repository": {"slug": "cli", "project": {"key": "TOOLS"}}},
+		"reviewers": [{"user": {"name": "alice"}}]
+	}`, string(bytes))
+}
@@ -0,0 +1,105 @@
+package codeowners
+
+import (
+	"github.com/transcovo/foreachrepo/gitignore"
+	"github.com/transcovo/foreachrepo/vfs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// LOCATIONS are the places where Github looks for a CODEOWNERS file, in order of precedence
+var LOCATIONS = []string{
+	".github/CODEOWNERS",
+	"CODEOWNERS",
+	"docs/CODEOWNERS",
+}
+
+type Rule struct {
+	Pattern string
+	Owners  []string
+	regexp  *regexp.Regexp
+}
+
+type CodeOwners struct {
+	Rules []Rule
+}
+
+// Parse reads the content of a CODEOWNERS file. Invalid patterns are ignored, as Github does.
+func Parse(content string) *CodeOwners {
+	codeOwners := &CodeOwners{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if comment := strings.Index(line, " #"); comment >= 0 {
+			line = line[:comment]
+		}
+		fields := strings.Fields(line)
+		compiled, err := gitignore.Compile(fields[0])
+		if err != nil {
+			continue
+		}
+		codeOwners.Rules = append(codeOwners.Rules, Rule{fields[0], fields[1:], compiled})
+	}
+	return codeOwners
+}
+
+// Find loads the CODEOWNERS file of the repo, returns nil if there is none
+func Find(files vfs.FS) (*CodeOwners, error) {
+	for _, location := range LOCATIONS {
+		bytes, err := files.ReadFile(location)
+		if err == nil {
+			return Parse(string(bytes)), nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// OwnersOf returns the owners of a path, the last matching rule taking precedence
+func (C *CodeOwners) OwnersOf(path string) []string {
+	path = strings.TrimPrefix(filepath.ToSlash(path), "/")
+	for i := len(C.Rules) - 1; i >= 0; i-- {
+		if C.Rules[i].regexp.MatchString(path) {
+			return C.Rules[i].Owners
+		}
+	}
+	return nil
+}
+
+// OwnersOfAll returns the owners of any of the paths, without duplicates
+func (C *CodeOwners) OwnersOfAll(paths []string) []string {
+	owners := []string{}
+	seen := map[string]bool{}
+	for _, path := range paths {
+		for _, owner := range C.OwnersOf(path) {
+			if !seen[owner] {
+				seen[owner] = true
+				owners = append(owners, owner)
+			}
+		}
+	}
+	return owners
+}
+
+// SplitOwners sorts owners into users and team slugs, as expected by the requested_reviewers endpoint.
+// Owners given by email can't be requested for review and are dropped.
+func SplitOwners(owners []string) (users []string, teams []string) {
+	for _, owner := range owners {
+		owner = strings.TrimPrefix(owner, "@")
+		if strings.Contains(owner, "@") {
+			continue
+		}
+		if slash := strings.Index(owner, "/"); slash >= 0 {
+			teams = append(teams, owner[slash + 1:])
+		} else if owner != "" {
+			users = append(users, owner)
+		}
+	}
+	return
+}
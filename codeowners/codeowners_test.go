@@ -0,0 +1,85 @@
+package codeowners
+
+import (
+	"github.com/transcovo/foreachrepo/vfs"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const SAMPLE_CODEOWNERS = `# Default owners
+*       @org/backend
+
+# Javascript files
+*.js    @js-owner # inline comment
+
+/build/logs/ @doctocat
+docs/*  docs@example.com
+apps/   @octocat
+/scripts/**/deploy.sh @org/ops @deployer
+`
+
+func TestOwnersOf(t *testing.T) {
+	codeOwners := Parse(SAMPLE_CODEOWNERS)
+	assert.Len(t, codeOwners.Rules, 6)
+
+	assert.Equal(t, []string{"@org/backend"}, codeOwners.OwnersOf("README.md"))
+	assert.Equal(t, []string{"@js-owner"}, codeOwners.OwnersOf("index.js"))
+	assert.Equal(t, []string{"@js-owner"}, codeOwners.OwnersOf("lib/deep/index.js"))
+	assert.Equal(t, []string{"@doctocat"}, codeOwners.OwnersOf("build/logs/a.log"))
+	assert.Equal(t, []string{"@org/backend"}, codeOwners.OwnersOf("src/build/logs/a.log"))
+	assert.Equal(t, []string{"docs@example.com"}, codeOwners.OwnersOf("docs/index.md"))
+	assert.Equal(t, []string{"@org/backend"}, codeOwners.OwnersOf("docs/guides/setup.md"))
+	assert.Equal(t, []string{"@octocat"}, codeOwners.OwnersOf("apps/a/b.js"))
+	assert.Equal(t, []string{"@octocat"}, codeOwners.OwnersOf("nested/apps/b.txt"))
+	assert.Equal(t, []string{"@org/ops", "@deployer"}, codeOwners.OwnersOf("scripts/deploy.sh"))
+	assert.Equal(t, []string{"@org/ops", "@deployer"}, codeOwners.OwnersOf("scripts/prod/eu/deploy.sh"))
+}
+
+func TestOwnersOfNoMatch(t *testing.T) {
+	codeOwners := Parse("/src/ @octocat\n")
+	assert.Nil(t, codeOwners.OwnersOf("package.json"))
+	assert.Nil(t, codeOwners.OwnersOf("lib/src/index.js"))
+}
+
+func TestOwnersOfAll(t *testing.T) {
+	codeOwners := Parse(SAMPLE_CODEOWNERS)
+	owners := codeOwners.OwnersOfAll([]string{"package.json", "index.js", "lib/index.js", "README.md"})
+	assert.Equal(t, []string{"@org/backend", "@js-owner"}, owners)
+}
+
+func TestSplitOwners(t *testing.T) {
+	users, teams := SplitOwners([]string{"@org/backend", "@octocat", "docs@example.com", "hubot"})
+	assert.Equal(t, []string{"octocat", "hubot"}, users)
+	assert.Equal(t, []string{"backend"}, teams)
+}
+
+func TestFind(t *testing.T) {
+	dir, mkdir_err := ioutil.TempDir("", "")
+	if mkdir_err != nil {
+		panic(mkdir_err)
+	}
+	defer os.RemoveAll(dir)
+
+	codeOwners, err := Find(&vfs.OS{Root: dir})
+	assert.Nil(t, err)
+	assert.Nil(t, codeOwners)
+
+	os.Mkdir(filepath.Join(dir, "docs"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "docs", "CODEOWNERS"), []byte("* @octocat\n"), 0644)
+	codeOwners, err = Find(&vfs.OS{Root: dir})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"@octocat"}, codeOwners.OwnersOf("package.json"))
+
+	os.Mkdir(filepath.Join(dir, ".github"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, ".github", "CODEOWNERS"), []byte("* @hubot\n"), 0644)
+	codeOwners, err = Find(&vfs.OS{Root: dir})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"@hubot"}, codeOwners.OwnersOf("package.json"))
+
+	codeOwners, err = Find(vfs.NewMemory(map[string][]byte{"CODEOWNERS": []byte("*.js @octocat\n")}))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"@octocat"}, codeOwners.OwnersOf("index.js"))
+}
@@ -0,0 +1,181 @@
+package filetemplate
+
+import (
+	"bytes"
+	"context"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/tasks"
+	"github.com/transcovo/foreachrepo/vfs"
+	"os"
+	"strings"
+	"text/template"
+)
+
+const (
+	// MODE_CREATE_ONLY writes the file of repos not having it yet
+	MODE_CREATE_ONLY = "create-only"
+	// MODE_OVERWRITE replaces the whole file
+	MODE_OVERWRITE = "overwrite"
+	// MODE_ENSURE_BLOCK manages a section between markers inside the file, leaving the rest as is
+	MODE_ENSURE_BLOCK = "ensure-block"
+)
+
+// BLOCK_NAME tells the blocks managed by foreachrepo apart in their markers
+const BLOCK_NAME = "foreachrepo managed block"
+
+type UnknownMode struct {
+	mode string
+}
+
+func (U *UnknownMode) Error() string {
+	return "Unknown mode " + U.mode + ", expected " + MODE_CREATE_ONLY + ", " + MODE_OVERWRITE + " or " + MODE_ENSURE_BLOCK
+}
+
+type AlreadyExists struct {
+	path string
+}
+
+func (A *AlreadyExists) Error() string {
+	return A.path + " already exists"
+}
+
+type UpToDate struct {
+	path string
+}
+
+func (U *UpToDate) Error() string {
+	return U.path + " is already up to date"
+}
+
+type UnterminatedBlock struct {
+	path string
+}
+
+func (U *UnterminatedBlock) Error() string {
+	return "The managed block of " + U.path + " has no end marker"
+}
+
+// Data is what the templates are rendered with
+type Data struct {
+	Repo    github.Repo
+	Path    string
+	// whether the file exists, and its content before the task
+	Exists  bool
+	Content string
+}
+
+// Task renders a Go template to a file of every repo. Besides Data, the templates can read any file of the repo
+// with {{ file "path" }}, empty when missing.
+type Task struct {
+	Path     string
+	Mode     string
+	// the line comment prefix of the file, such as # or //, for the markers of the managed block
+	Comment  string
+	template *template.Template
+	repo     github.Repo
+}
+
+func NewTask(path string, source string, mode string, comment string) (*Task, error) {
+	if mode != MODE_CREATE_ONLY && mode != MODE_OVERWRITE && mode != MODE_ENSURE_BLOCK {
+		return nil, &UnknownMode{mode}
+	}
+	// the file function is bound to the files of each repo when rendering
+	parsed, err := template.New(path).Option("missingkey=error").Funcs(template.FuncMap{
+		"file": func(name string) (string, error) { return "", nil },
+	}).Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	return &Task{Path: path, Mode: mode, Comment: comment, template: parsed}, nil
+}
+
+func (T *Task) Files() []string {
+	return []string{T.Path}
+}
+
+func (T *Task) ForRepo(repo github.Repo) tasks.Task {
+	bound := *T
+	bound.repo = repo
+	return &bound
+}
+
+func (T *Task) render(files vfs.FS, data Data) (string, error) {
+	rendering, err := T.template.Clone()
+	if err != nil {
+		return "", err
+	}
+	rendering.Funcs(template.FuncMap{
+		"file": func(name string) (string, error) {
+			content, err := files.ReadFile(name)
+			if os.IsNotExist(err) {
+				return "", nil
+			}
+			return string(content), err
+		},
+	})
+	var buffer bytes.Buffer
+	if err := rendering.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+func (T *Task) markers() (string, string) {
+	return T.Comment + " BEGIN " + BLOCK_NAME, T.Comment + " END " + BLOCK_NAME
+}
+
+// EnsureBlock replaces the managed block of a content with the rendered one, appending it when missing
+func (T *Task) EnsureBlock(content string, block string) (string, error) {
+	begin, end := T.markers()
+	if !strings.HasSuffix(block, "\n") {
+		block += "\n"
+	}
+	managed := begin + "\n" + block + end + "\n"
+
+	start := strings.Index(content, begin + "\n")
+	if start == -1 {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		if content != "" {
+			content += "\n"
+		}
+		return content + managed, nil
+	}
+	length := strings.Index(content[start:], end)
+	if length == -1 {
+		return "", &UnterminatedBlock{T.Path}
+	}
+	stop := start + length + len(end)
+	if strings.HasPrefix(content[stop:], "\n") {
+		stop += 1
+	}
+	return content[:start] + managed + content[stop:], nil
+}
+
+func (T *Task) Execute(ctx context.Context, files vfs.FS) error {
+	data := Data{Repo: T.repo, Path: T.Path}
+	existing, err := files.ReadFile(T.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	data.Exists, data.Content = err == nil, string(existing)
+	if data.Exists && T.Mode == MODE_CREATE_ONLY {
+		return &AlreadyExists{T.Path}
+	}
+
+	rendered, err := T.render(files, data)
+	if err != nil {
+		return err
+	}
+	if T.Mode == MODE_ENSURE_BLOCK {
+		rendered, err = T.EnsureBlock(data.Content, rendered)
+		if err != nil {
+			return err
+		}
+	}
+	if data.Exists && rendered == data.Content {
+		return &UpToDate{T.Path}
+	}
+	return files.WriteFile(T.Path, []byte(rendered), 0644)
+}
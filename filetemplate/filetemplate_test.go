@@ -0,0 +1,66 @@
+package filetemplate
+
+import (
+	"context"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/vfs"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func bound(t *testing.T, path string, source string, mode string) *Task {
+	task, err := NewTask(path, source, mode, "#")
+	assert.Nil(t, err)
+	return task.ForRepo(github.Repo{Name: "repo1", FullName: "org/repo1", Language: "Go"}).(*Task)
+}
+
+func TestCreateOnly(t *testing.T) {
+	task := bound(t, ".github/CODEOWNERS", "* @{{ .Repo.Owner }}/backend\n", MODE_CREATE_ONLY)
+	files := vfs.NewMemory(nil)
+	assert.Nil(t, task.Execute(context.Background(), files))
+	content, _ := files.ReadFile(".github/CODEOWNERS")
+	assert.Equal(t, "* @org/backend\n", string(content))
+
+	err := task.Execute(context.Background(), files)
+	assert.Equal(t, ".github/CODEOWNERS already exists", err.Error())
+}
+
+func TestOverwrite(t *testing.T) {
+	task := bound(t, ".editorconfig", "# {{ .Repo.Name }} ({{ .Repo.Language }})\n{{ file \"VERSION\" }}", MODE_OVERWRITE)
+	files := vfs.NewMemory(map[string][]byte{".editorconfig": []byte("old"), "VERSION": []byte("1.0")})
+	assert.Nil(t, task.Execute(context.Background(), files))
+	content, _ := files.ReadFile(".editorconfig")
+	assert.Equal(t, "# repo1 (Go)\n1.0", string(content))
+
+	err := task.Execute(context.Background(), files)
+	assert.Equal(t, ".editorconfig is already up to date", err.Error())
+}
+
+func TestEnsureBlock(t *testing.T) {
+	task := bound(t, ".gitignore", "node_modules/\n.env", MODE_ENSURE_BLOCK)
+	files := vfs.NewMemory(map[string][]byte{".gitignore": []byte("dist/")})
+	assert.Nil(t, task.Execute(context.Background(), files))
+	content, _ := files.ReadFile(".gitignore")
+	expected := "dist/\n\n# BEGIN foreachrepo managed block\nnode_modules/\n.env\n# END foreachrepo managed block\n"
+	assert.Equal(t, expected, string(content))
+
+	err := task.Execute(context.Background(), files)
+	assert.Equal(t, ".gitignore is already up to date", err.Error())
+}
+
+func TestEnsureBlockReplaces(t *testing.T) {
+	task := bound(t, ".gitignore", "coverage/\n", MODE_ENSURE_BLOCK)
+	content, err := task.EnsureBlock("a\n# BEGIN foreachrepo managed block\nold\n# END foreachrepo managed block\nb\n", "coverage/\n")
+	assert.Nil(t, err)
+	assert.Equal(t, "a\n# BEGIN foreachrepo managed block\ncoverage/\n# END foreachrepo managed block\nb\n", content)
+
+	_, err = task.EnsureBlock("# BEGIN foreachrepo managed block\nold\n", "coverage/\n")
+	assert.Equal(t, "The managed block of .gitignore has no end marker", err.Error())
+}
+
+func TestNewTaskErrors(t *testing.T) {
+	_, err := NewTask(".editorconfig", "", "append", "#")
+	assert.Contains(t, err.Error(), "Unknown mode append")
+	_, err = NewTask(".editorconfig", "{{ .Repo", MODE_OVERWRITE, "#")
+	assert.NotNil(t, err)
+}
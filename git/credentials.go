@@ -0,0 +1,42 @@
+package git
+
+import (
+	"net/url"
+)
+
+// CREDENTIAL_HELPER answers git with the credentials found in the environment of the command
+const CREDENTIAL_HELPER = `!f() { test "$1" = get && echo "username=$FOREACHREPO_GIT_USERNAME" && echo "password=$FOREACHREPO_GIT_PASSWORD"; }; f`
+
+// HttpsCredentials authenticate the git commands run over HTTPS, typically with an API token as password. They are
+// handed to git by an inline credential helper reading them from the environment of the command, so that they are
+// never written to the repo config, to disk, or in the command lines that get logged.
+type HttpsCredentials struct {
+	// Url is the origin the credentials are given to, such as https://github.com, other hosts getting none
+	Url      string
+	Username string
+	Password string
+}
+
+// args replaces the credential helpers of the host by ours, for the url of the credentials only
+func (H *HttpsCredentials) args() []string {
+	key := "credential." + H.Url + ".helper="
+	return []string{"-c", key, "-c", key + CREDENTIAL_HELPER}
+}
+
+func (H *HttpsCredentials) env() []string {
+	return []string{
+		"FOREACHREPO_GIT_USERNAME=" + H.Username,
+		"FOREACHREPO_GIT_PASSWORD=" + H.Password,
+		// fail instead of prompting when the credentials are refused
+		"GIT_TERMINAL_PROMPT=0",
+	}
+}
+
+// UrlOrigin returns the scheme and host of an HTTP(S) url, the key of its credentials, and empty for SSH urls
+func UrlOrigin(rawUrl string) string {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return ""
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
@@ -0,0 +1,93 @@
+package git
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+// gitHttpServer serves the repos of root with git http-backend, to the requests authenticated with the token
+func gitHttpServer(root string, token string) *httptest.Server {
+	execPath, err := exec.Command("git", "--exec-path").Output()
+	if err != nil {
+		panic(err)
+	}
+	backend := &cgi.Handler{
+		Path: filepath.Join(strings.TrimSpace(string(execPath)), "git-http-backend"),
+		Env: []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, password, ok := r.BasicAuth()
+		if !ok || password != token {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			w.WriteHeader(401)
+			return
+		}
+		// http-backend refuses pushes from anonymous users
+		r.Header.Set("Remote-User", "bot")
+		backend.ServeHTTP(w, r)
+	}))
+}
+
+func TestCloneAndPushWithCredentials(t *testing.T) {
+	root := tempDir()
+	defer os.RemoveAll(root)
+	Git(root).Exec("git", "init", "--bare", "repo1.git")
+	Git(filepath.Join(root, "repo1.git")).Exec("git", "config", "http.receivepack", "true")
+	server := gitHttpServer(root, "s3cr3t")
+	defer server.Close()
+	url := server.URL + "/repo1.git"
+
+	refused := Git("")
+	refused.SetCredentials(&HttpsCredentials{Url: server.URL, Username: "bot", Password: "wrong"})
+	refusedDir, err := refused.Clone(url)
+	defer os.RemoveAll(refused.Dir)
+	assert.Empty(t, refusedDir)
+	assert.Equal(t, FAILURE_AUTH, Classify(err))
+	assert.NotContains(t, err.Error(), "credential." + server.URL + ".helper")
+	assert.NotContains(t, err.Error(), CREDENTIAL_HELPER)
+
+	g := Git("")
+	g.SetCredentials(&HttpsCredentials{Url: server.URL, Username: "bot", Password: "s3cr3t"})
+	dir, err := g.Clone(url)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, err)
+
+	g.Exec("touch", "file1.txt")
+	err = g.CommitAndPushInNewBranchTo("origin", "add-1", "Add a file", CommitOptions{AuthorName: "Bot", AuthorEmail: "bot@example.com"})
+	assert.Nil(t, err)
+
+	branches, _ := Git(filepath.Join(root, "repo1.git")).Output("git", "branch")
+	assert.Contains(t, branches, "add-1")
+	config, _ := ioutil.ReadFile(filepath.Join(dir, ".git", "config"))
+	assert.NotContains(t, string(config), "s3cr3t")
+	assert.NotContains(t, string(config), "credential")
+}
+
+func TestCredentialsOfAnotherHost(t *testing.T) {
+	root := tempDir()
+	defer os.RemoveAll(root)
+	Git(root).Exec("git", "init", "--bare", "repo1.git")
+	server := gitHttpServer(root, "s3cr3t")
+	defer server.Close()
+
+	g := Git("")
+	g.SetCredentials(&HttpsCredentials{Url: "https://github.com", Username: "bot", Password: "s3cr3t"})
+	dir, err := g.Clone(server.URL + "/repo1.git")
+	defer os.RemoveAll(g.Dir)
+	assert.Empty(t, dir)
+	assert.Equal(t, FAILURE_AUTH, Classify(err))
+}
+
+func TestUrlOrigin(t *testing.T) {
+	assert.Equal(t, "https://gitlab.example.com:8443", UrlOrigin("https://gitlab.example.com:8443/group/project.git"))
+	assert.Equal(t, "http://127.0.0.1:3000", UrlOrigin("http://127.0.0.1:3000/tools/cli.git"))
+	assert.Equal(t, "", UrlOrigin("git@github.com:org/repo1.git"))
+}
@@ -0,0 +1,133 @@
+package git
+
+import (
+	"context"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	FAILURE_AUTH = "auth denied"
+	FAILURE_NOT_FOUND = "repository not found"
+	FAILURE_PROTECTED_BRANCH = "protected branch"
+	FAILURE_NON_FAST_FORWARD = "non-fast-forward"
+	FAILURE_TIMEOUT = "timeout"
+	FAILURE_INTERRUPTED = "interrupted"
+	FAILURE_OTHER = "other"
+)
+
+// the number of output lines kept in a GitError
+const OUTPUT_TAIL_LINES = 20
+
+// failurePatterns classifies failures from the git output, the first match winning
+var failurePatterns = []struct {
+	kind    string
+	pattern *regexp.Regexp
+}{
+	{FAILURE_PROTECTED_BRANCH, regexp.MustCompile(`(?i)protected branch`)},
+	{FAILURE_NOT_FOUND, regexp.MustCompile(`(?i)repository not found|repository '[^']*' not found|does not appear to be a git repository`)},
+	{FAILURE_AUTH, regexp.MustCompile(`(?i)permission denied|authentication failed|could not read username|returned error: 403|invalid username or password`)},
+	{FAILURE_NON_FAST_FORWARD, regexp.MustCompile(`(?i)non-fast-forward|\[rejected\]|fetch first`)},
+}
+
+// GitError is a failed command, with what it printed
+type GitError struct {
+	Command  string
+	Args     []string
+	Dir      string
+	// -1 when the command could not be started
+	ExitCode int
+	// the last lines of the combined stdout and stderr
+	Output   string
+	Kind     string
+	Cause    error
+}
+
+func (G *GitError) Error() string {
+	message := G.Command + " " + strings.Join(G.Args, " ") + " failed"
+	if G.ExitCode >= 0 {
+		message += " (exit " + strconv.Itoa(G.ExitCode) + ", " + G.Kind + ")"
+	} else {
+		message += " (" + G.Cause.Error() + ")"
+	}
+	if G.Output != "" {
+		message += ": " + G.Output
+	}
+	return message
+}
+
+// Classify returns the kind of failure of err, FAILURE_OTHER when it isn't a GitError or isn't a known one
+func Classify(err error) string {
+	if gitErr, ok := err.(*GitError); ok {
+		return gitErr.Kind
+	}
+	return FAILURE_OTHER
+}
+
+func classify(output string) string {
+	for _, failure := range failurePatterns {
+		if failure.pattern.MatchString(output) {
+			return failure.kind
+		}
+	}
+	return FAILURE_OTHER
+}
+
+func tail(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > OUTPUT_TAIL_LINES {
+		lines = lines[len(lines) - OUTPUT_TAIL_LINES:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ContextFailure returns the kind of failure of a command killed because the context is done, "" if it isn't
+func ContextFailure(ctx context.Context) string {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return FAILURE_TIMEOUT
+	case context.Canceled:
+		return FAILURE_INTERRUPTED
+	}
+	return ""
+}
+
+// isCredentialHelper tells whether a setting is a credential helper, scoped to a url or not
+func isCredentialHelper(setting string) bool {
+	return strings.HasPrefix(setting, "credential.") && strings.Contains(setting, ".helper=")
+}
+
+// withoutCredentialHelpers removes the credential helpers settings from the args, to keep the errors readable
+func withoutCredentialHelpers(args []string) []string {
+	result := []string{}
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-c" && i + 1 < len(args) && isCredentialHelper(args[i + 1]) {
+			i++
+			continue
+		}
+		result = append(result, args[i])
+	}
+	return result
+}
+
+func newGitError(ctx context.Context, cmd *exec.Cmd, output string, cause error) *GitError {
+	exitCode := -1
+	if exitErr, ok := cause.(*exec.ExitError); ok {
+		exitCode = exitErr.ExitCode()
+	}
+	kind := ContextFailure(ctx)
+	if kind == "" {
+		kind = classify(output)
+	}
+	return &GitError{
+		Command: cmd.Args[0],
+		Args: withoutCredentialHelpers(cmd.Args[1:]),
+		Dir: cmd.Dir,
+		ExitCode: exitCode,
+		Output: tail(output),
+		Kind: kind,
+		Cause: cause,
+	}
+}
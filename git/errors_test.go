@@ -0,0 +1,75 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecGitError(t *testing.T) {
+	dir := tempDir()
+	defer os.RemoveAll(dir)
+
+	g := Git(dir)
+	err := g.Exec("sh", "-c", "echo Cloning; echo 'ERROR: Repository not found.' >&2; exit 128")
+
+	gitErr, ok := err.(*GitError)
+	assert.True(t, ok)
+	assert.Equal(t, "sh", gitErr.Command)
+	assert.Equal(t, dir, gitErr.Dir)
+	assert.Equal(t, 128, gitErr.ExitCode)
+	assert.Equal(t, "Cloning\nERROR: Repository not found.", gitErr.Output)
+	assert.Equal(t, FAILURE_NOT_FOUND, gitErr.Kind)
+	assert.Contains(t, err.Error(), "(exit 128, repository not found): Cloning")
+}
+
+func TestExecGitErrorNotStarted(t *testing.T) {
+	g := Git("")
+	g.setSys(&CommandFailSys{})
+	err := g.Exec("git", "--version")
+
+	gitErr, ok := err.(*GitError)
+	assert.True(t, ok)
+	assert.Equal(t, -1, gitErr.ExitCode)
+	assert.Equal(t, FAILURE_OTHER, gitErr.Kind)
+}
+
+func TestClassify(t *testing.T) {
+	assert.Equal(t, FAILURE_AUTH, classify("git@github.com: Permission denied (publickey).\nfatal: Could not read from remote repository."))
+	assert.Equal(t, FAILURE_AUTH, classify("fatal: Authentication failed for 'https://github.com/org/repo1.git/'"))
+	assert.Equal(t, FAILURE_NOT_FOUND, classify("remote: Repository not found.\nfatal: repository 'https://github.com/org/repo1.git/' not found"))
+	assert.Equal(t, FAILURE_PROTECTED_BRANCH, classify(" ! [remote rejected] master -> master (protected branch hook declined)"))
+	assert.Equal(t, FAILURE_NON_FAST_FORWARD, classify(" ! [rejected]        a-branch -> a-branch (non-fast-forward)"))
+	assert.Equal(t, FAILURE_OTHER, classify("fatal: unable to access"))
+
+	assert.Equal(t, FAILURE_OTHER, Classify(errors.New("No package.json found")))
+}
+
+func TestGitErrorOutputTail(t *testing.T) {
+	g := Git("")
+	err := g.Exec("sh", "-c", "seq 1 100; exit 1")
+	lines := strings.Split(err.(*GitError).Output, "\n")
+	assert.Len(t, lines, OUTPUT_TAIL_LINES)
+	assert.Equal(t, "100", lines[len(lines) - 1])
+}
+
+func TestExecTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50 * time.Millisecond)
+	defer cancel()
+
+	g := GitContext(ctx, "")
+	err := g.Exec("sleep", "10")
+	assert.Equal(t, FAILURE_TIMEOUT, Classify(err))
+}
+
+func TestWithoutCredentialHelpers(t *testing.T) {
+	assert.Equal(t, []string{"clone", "https://example.com/a.git"}, withoutCredentialHelpers([]string{
+		"-c", "credential.https://example.com.helper=", "-c", "credential.https://example.com.helper=!f() { :; }; f",
+		"-c", "credential.helper=", "clone", "https://example.com/a.git"}))
+	assert.Equal(t, []string{"-c", "credential.username=bot", "push"},
+		withoutCredentialHelpers([]string{"-c", "credential.username=bot", "push"}))
+}
@@ -1,43 +1,123 @@
 package git
 
 import (
+	"bytes"
+	"context"
+	"github.com/transcovo/foreachrepo/process"
 	"io/ioutil"
+	"os"
 	"os/exec"
 	"log"
 	"errors"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
 type Sys interface {
-	Command(name string, arg ...string) *exec.Cmd
+	Command(ctx context.Context, name string, arg ...string) *exec.Cmd
 	TempDir(dir, prefix string) (name string, err error)
 }
 
 type ActualSys struct{}
 
-func (c ActualSys) Command(name string, arg ...string) *exec.Cmd {
-	return exec.Command(name, arg...)
+// Command kills the command and its children when the context is done
+func (c ActualSys) Command(ctx context.Context, name string, arg ...string) *exec.Cmd {
+	return process.Command(ctx, name, arg...)
 }
 func (c ActualSys) TempDir(dir, prefix string) (string, error) {
 	return ioutil.TempDir(dir, prefix)
 }
 
 type git struct {
-	Sys Sys
-	Dir string
+	Sys         Sys
+	Dir         string
+	ctx         context.Context
+	credentials *HttpsCredentials
 }
 
 func Git(dir string) *git {
-	return &git{&ActualSys{}, dir}
+	return GitContext(context.Background(), dir)
+}
+
+// GitContext returns a repo whose commands are killed when the context is done
+func GitContext(ctx context.Context, dir string) *git {
+	return &git{&ActualSys{}, dir, ctx, nil}
+}
+
+// SetCredentials authenticates the git commands run over HTTPS from now on
+func (g *git) SetCredentials(credentials *HttpsCredentials) {
+	g.credentials = credentials
 }
 
 func (g *git) setSys(sys Sys) {
 	g.Sys = sys
 }
 
+// CloneOptions trades a complete local history for faster clones
+type CloneOptions struct {
+	// Depth truncates the history to that many commits, 0 keeps it all
+	Depth    int
+	// Partial skips the file contents not needed by the checkout, fetched on demand later
+	Partial  bool
+	// CacheDir keeps a mirror of every cloned repo, fetched on later runs and referenced by the working copies
+	CacheDir string
+}
+
 func (g *git) Clone(url string) (string, error) {
+	return g.CloneWith(url, CloneOptions{})
+}
+
+var unsafeCacheChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// mirrorDir is where the mirror of a repo is kept in the cache
+func mirrorDir(cacheDir string, url string) string {
+	return filepath.Join(cacheDir, unsafeCacheChars.ReplaceAllString(url, "_") + ".git")
+}
+
+// updateMirror clones the repo in the cache, or fetches it when it is already there
+func (g *git) updateMirror(url string, options CloneOptions) (string, error) {
+	mirror := mirrorDir(options.CacheDir, url)
+	if _, err := os.Stat(mirror); err == nil {
+		log.Println("Updating cached repo ", mirror)
+		return mirror, g.Exec("git", "--git-dir", mirror, "remote", "update", "--prune")
+	}
+	if err := os.MkdirAll(options.CacheDir, 0755); err != nil {
+		return "", err
+	}
+	log.Println("Caching repo ", url, " in ", mirror)
+	args := []string{"clone", "--mirror"}
+	if options.Partial {
+		args = append(args, "--filter=blob:none")
+	}
+	err := g.Exec("git", append(args, url, mirror)...)
+	if err != nil {
+		os.RemoveAll(mirror)
+	}
+	return mirror, err
+}
+
+func (g *git) CloneWith(url string, options CloneOptions) (string, error) {
 	if g.Dir != "" {
 		return "", errors.New("This git repo's dir is already initialized: " + g.Dir)
 	}
+	args := []string{"clone"}
+	if options.CacheDir != "" {
+		mirror, cacheErr := g.updateMirror(url, options)
+		if cacheErr != nil {
+			log.Println("Could not cache repo ", url, ": ", cacheErr.Error())
+		} else {
+			args = append(args, "--reference", mirror)
+		}
+	}
+	if options.Depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(options.Depth))
+	}
+	if options.Partial {
+		args = append(args, "--filter=blob:none")
+	}
+
 	log.Println("Cloning repo ", url)
 	dir, mkdirErr := g.Sys.TempDir("", "")
 	if mkdirErr != nil {
@@ -45,43 +125,199 @@ func (g *git) Clone(url string) (string, error) {
 	}
 	g.Dir = dir
 
-	gitErr := g.Exec("git", "clone", url, dir)
+	gitErr := g.Exec("git", append(args, url, dir)...)
 	if gitErr != nil {
 		return "", gitErr
 	}
 	return dir, nil
 }
 
-func (g *git) Exec(name string, elements ...string) error {
-	cmd := g.Sys.Command(name, elements...)
+// command prepares a command run in the repo dir, git being given the credentials if any
+func (g *git) command(name string, elements ...string) *exec.Cmd {
+	if name == "git" && g.credentials != nil {
+		elements = append(g.credentials.args(), elements...)
+	}
+	cmd := g.Sys.Command(g.ctx, name, elements...)
 	if g.Dir != "" {
 		cmd.Dir = g.Dir
 	}
+	if name == "git" && g.credentials != nil {
+		cmd.Env = append(os.Environ(), g.credentials.env()...)
+	}
+	return cmd
+}
+
+// Exec runs the command in the repo dir, its failures being returned as a GitError
+func (g *git) Exec(name string, elements ...string) error {
+	return g.execEnv(nil, name, elements...)
+}
+
+// execEnv runs the command like Exec, with the variables added to its environment
+func (g *git) execEnv(env []string, name string, elements ...string) error {
+	cmd := g.command(name, elements...)
+	if len(env) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, env...)
+	}
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
 	startErr := cmd.Start()
 	if startErr != nil {
-		return startErr
+		return newGitError(g.ctx, cmd, "", startErr)
 	}
 	waitErr := cmd.Wait()
 	if waitErr != nil {
-		return waitErr
+		return newGitError(g.ctx, cmd, output.String(), waitErr)
 	}
 	return nil
 }
 
+// Output runs the command in the repo dir and returns what it wrote on stdout
+func (g *git) Output(name string, elements ...string) (string, error) {
+	cmd := g.command(name, elements...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return string(out), newGitError(g.ctx, cmd, stderr.String(), err)
+	}
+	return string(out), nil
+}
+
+// ChangedFiles lists the paths modified, added or removed in the working copy, untracked files included
+func (g *git) ChangedFiles() ([]string, error) {
+	out, err := g.Output("git", "status", "--porcelain", "-z", "--untracked-files=all", "--no-renames")
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, entry := range strings.Split(out, "\x00") {
+		if len(entry) > 3 {
+			files = append(files, entry[3:])
+		}
+	}
+	return files, nil
+}
+
+// CurrentRef returns the branch checked out, or the commit when the HEAD is detached
+func (g *git) CurrentRef() (string, error) {
+	out, err := g.Output("git", "rev-parse", "--abbrev-ref", "HEAD")
+	if err == nil && strings.TrimSpace(out) == "HEAD" {
+		out, err = g.Output("git", "rev-parse", "HEAD")
+	}
+	return strings.TrimSpace(out), err
+}
+
+// Restore discards the changes of the working copy, ignored files excepted, and checks the ref out
+func (g *git) Restore(ref string) error {
+	err := g.Exec("git", "reset", "--hard", "--quiet")
+	if err == nil {
+		err = g.Exec("git", "clean", "-fd", "--quiet")
+	}
+	if err == nil {
+		err = g.Exec("git", "checkout", "--quiet", ref)
+	}
+	return err
+}
+
+// Stage adds the changes of the working copy to the index, untracked files included
+func (g *git) Stage() error {
+	return g.Exec("git", "add", "-A")
+}
+
+// DiscardUnstaged drops the changes made since the last Stage, such as build outputs, ignored files excepted
+func (g *git) DiscardUnstaged() error {
+	err := g.Exec("git", "checkout", "--quiet", "--", ".")
+	if err == nil {
+		err = g.Exec("git", "clean", "-fd", "--quiet")
+	}
+	return err
+}
+
 func (g *git) IsInstalled() bool {
 	return g.Exec("git", "--version") == nil
 }
 
+// CommitOptions sets who the commits are from and how they are signed, the git config of the host being used otherwise
+type CommitOptions struct {
+	AuthorName    string
+	AuthorEmail   string
+	// add a Signed-off-by trailer for the author
+	SignOff       bool
+	// added as Co-authored-by trailers, as "Name <email>"
+	CoAuthors     []string
+	// sign the commits, with SigningKey or the default key of the host
+	Sign          bool
+	SigningKey    string
+	// openpgp (the default), ssh or x509
+	SigningFormat string
+}
+
+// CommitMessage adds the co-author trailers of the options to the message
+func CommitMessage(message string, options CommitOptions) string {
+	if len(options.CoAuthors) > 0 {
+		message += "\n"
+		for _, coAuthor := range options.CoAuthors {
+			message += "\nCo-authored-by: " + coAuthor
+		}
+	}
+	return message
+}
+
+// commitEnv returns the variables setting the identity of the commit. They take precedence over the git config and
+// over the same variables of the environment foreachrepo runs in.
+func commitEnv(options CommitOptions) []string {
+	env := []string{}
+	if options.AuthorName != "" {
+		env = append(env, "GIT_AUTHOR_NAME=" + options.AuthorName, "GIT_COMMITTER_NAME=" + options.AuthorName)
+	}
+	if options.AuthorEmail != "" {
+		env = append(env, "GIT_AUTHOR_EMAIL=" + options.AuthorEmail, "GIT_COMMITTER_EMAIL=" + options.AuthorEmail)
+	}
+	return env
+}
+
+// commitArgs returns the arguments of the commit command, signing settings included
+func commitArgs(message string, options CommitOptions) []string {
+	args := []string{}
+	if options.SigningKey != "" {
+		args = append(args, "-c", "user.signingkey=" + options.SigningKey)
+	}
+	if options.SigningFormat != "" {
+		args = append(args, "-c", "gpg.format=" + options.SigningFormat)
+	}
+
+	args = append(args, "commit", "-m", CommitMessage(message, options))
+	if options.SignOff {
+		args = append(args, "--signoff")
+	}
+	if options.Sign {
+		args = append(args, "--gpg-sign")
+	}
+	return args
+}
+
 func (g *git) CommitAndPushInNewBranch(branch string, message string) error {
+	return g.CommitAndPushInNewBranchTo("origin", branch, message, CommitOptions{})
+}
+
+func (g *git) AddRemote(name string, url string) error {
+	return g.Exec("git", "remote", "add", name, url)
+}
+
+func (g *git) CommitAndPushInNewBranchTo(remote string, branch string, message string, options CommitOptions) error {
 	err := g.Exec("git", "checkout", "-b", branch)
 	if err == nil {
 		err = g.Exec("git", "add", ".")
 	}
 	if err == nil {
-		err = g.Exec("git", "commit", "-m", message)
+		err = g.execEnv(commitEnv(options), "git", commitArgs(message, options)...)
 	}
 	if err == nil {
-		err = g.Exec("git", "push", "-u", "origin", branch)
+		err = g.Exec("git", "push", "-u", remote, branch)
 	}
 	return err
 }
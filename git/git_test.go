@@ -1,6 +1,7 @@
 package git
 
 import (
+	"context"
 	"testing"
 	"io/ioutil"
 	"os"
@@ -75,7 +76,7 @@ func TestCloneAlreadyInit(t *testing.T) {
 
 type TempDirFailSys struct{}
 
-func (c TempDirFailSys) Command(name string, arg ...string) *exec.Cmd {
+func (c TempDirFailSys) Command(ctx context.Context, name string, arg ...string) *exec.Cmd {
 	return exec.Command(name, arg...)
 }
 func (c TempDirFailSys) TempDir(dir, prefix string) (string, error) {
@@ -95,7 +96,7 @@ func TestCloneMkTempDirFail(t *testing.T) {
 
 type CommandFailSys struct{}
 
-func (c CommandFailSys) Command(name string, arg ...string) *exec.Cmd {
+func (c CommandFailSys) Command(ctx context.Context, name string, arg ...string) *exec.Cmd {
 	return exec.Command("sdjadzejeuqsoieqomequfqomeuzj")
 }
 func (c CommandFailSys) TempDir(dir, prefix string) (string, error) {
@@ -115,7 +116,7 @@ func TestCloneCommandFail(t *testing.T) {
 
 type CommandWaitFailSys struct{}
 
-func (c CommandWaitFailSys) Command(name string, arg ...string) *exec.Cmd {
+func (c CommandWaitFailSys) Command(ctx context.Context, name string, arg ...string) *exec.Cmd {
 	return exec.Command("sh", "-c", "sdjadzejeuqsoieqomequfqomeuzj")
 }
 func (c CommandWaitFailSys) TempDir(dir, prefix string) (string, error) {
@@ -176,3 +177,182 @@ func TestCommitAndPushInNewBranch(t *testing.T) {
 	assert.True(t, strings.HasSuffix(files[0], "file1.txt"), "file1.txt must be present")
 	assert.True(t, strings.HasSuffix(files[1], "file3.txt"), "file3.txt must be present")
 }
+
+func TestChangedFiles(t *testing.T) {
+	dir := tempDir()
+	defer os.RemoveAll(dir)
+
+	g := Git(dir)
+	g.Exec("git", "init")
+	g.Exec("mkdir", "lib")
+	g.Exec("touch", "file1.txt", "lib/file2.txt")
+
+	files, err := g.ChangedFiles()
+	assert.Nil(t, err)
+	sort.Strings(files)
+	assert.Equal(t, []string{"file1.txt", "lib/file2.txt"}, files)
+}
+
+// withIdentity gives the commits of the test an identity, whatever the git config of the host
+func withIdentity(t *testing.T) {
+	for _, name := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(name, "Test")
+	}
+	for _, name := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(name, "test@example.com")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	withIdentity(t)
+	dir, origin := makeRepo()
+	defer os.RemoveAll(dir)
+	defer os.RemoveAll(origin)
+	g := Git(dir)
+	g.Exec("git", "checkout", "a-branch")
+
+	ref, err := g.CurrentRef()
+	assert.Nil(t, err)
+	assert.Equal(t, "a-branch", ref)
+
+	g.Exec("git", "checkout", "-b", "campaign")
+	g.Exec("touch", "file3.txt")
+	ioutil.WriteFile(filepath.Join(dir, "file1.txt"), []byte("changed"), 0644)
+	assert.Nil(t, g.Restore(ref))
+
+	ref, _ = g.CurrentRef()
+	assert.Equal(t, "a-branch", ref)
+	files, _ := g.ChangedFiles()
+	assert.Empty(t, files)
+}
+
+func TestDiscardUnstaged(t *testing.T) {
+	withIdentity(t)
+	dir, origin := makeRepo()
+	defer os.RemoveAll(dir)
+	defer os.RemoveAll(origin)
+	g := Git(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "task.txt"), []byte("done"), 0644)
+	assert.Nil(t, g.Stage())
+	ioutil.WriteFile(filepath.Join(dir, "task.txt"), []byte("rebuilt"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "build.log"), []byte("built"), 0644)
+	assert.Nil(t, g.DiscardUnstaged())
+
+	files, _ := g.ChangedFiles()
+	assert.Equal(t, []string{"task.txt"}, files)
+	content, _ := ioutil.ReadFile(filepath.Join(dir, "task.txt"))
+	assert.Equal(t, "done", string(content))
+}
+
+type RecordingSys struct {
+	commands [][]string
+	dir      string
+}
+
+func (c *RecordingSys) Command(ctx context.Context, name string, arg ...string) *exec.Cmd {
+	c.commands = append(c.commands, append([]string{name}, arg...))
+	return exec.Command("true")
+}
+func (c *RecordingSys) TempDir(dir, prefix string) (string, error) {
+	c.dir = tempDir()
+	return c.dir, nil
+}
+
+func TestCloneWithShallowPartial(t *testing.T) {
+	sys := &RecordingSys{}
+	g := Git("")
+	g.setSys(sys)
+	dir, err := g.CloneWith("git@github.com:org/repo1.git", CloneOptions{Depth: 1, Partial: true})
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{
+		{"git", "clone", "--depth", "1", "--filter=blob:none", "git@github.com:org/repo1.git", sys.dir},
+	}, sys.commands)
+}
+
+func TestCloneWithNewCache(t *testing.T) {
+	cacheDir := tempDir()
+	defer os.RemoveAll(cacheDir)
+
+	sys := &RecordingSys{}
+	g := Git("")
+	g.setSys(sys)
+	dir, err := g.CloneWith("git@github.com:org/repo1.git", CloneOptions{CacheDir: cacheDir})
+	defer os.RemoveAll(dir)
+
+	mirror := filepath.Join(cacheDir, "git_github.com_org_repo1.git.git")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{
+		{"git", "clone", "--mirror", "git@github.com:org/repo1.git", mirror},
+		{"git", "clone", "--reference", mirror, "git@github.com:org/repo1.git", sys.dir},
+	}, sys.commands)
+}
+
+func TestCloneWithExistingCache(t *testing.T) {
+	cacheDir := tempDir()
+	defer os.RemoveAll(cacheDir)
+	mirror := filepath.Join(cacheDir, "git_github.com_org_repo1.git.git")
+	os.Mkdir(mirror, 0755)
+
+	sys := &RecordingSys{}
+	g := Git("")
+	g.setSys(sys)
+	dir, err := g.CloneWith("git@github.com:org/repo1.git", CloneOptions{CacheDir: cacheDir})
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{
+		{"git", "--git-dir", mirror, "remote", "update", "--prune"},
+		{"git", "clone", "--reference", mirror, "git@github.com:org/repo1.git", sys.dir},
+	}, sys.commands)
+}
+
+func TestCommitArgs(t *testing.T) {
+	assert.Equal(t, []string{"commit", "-m", "Bump"}, commitArgs("Bump", CommitOptions{}))
+	assert.Equal(t, []string{
+		"-c", "user.signingkey=~/.ssh/bot.pub", "-c", "gpg.format=ssh",
+		"commit", "-m", "Bump\n\nCo-authored-by: Jane <jane@example.com>", "--signoff", "--gpg-sign",
+	}, commitArgs("Bump", CommitOptions{
+		AuthorName: "Bot",
+		AuthorEmail: "bot@example.com",
+		SignOff: true,
+		CoAuthors: []string{"Jane <jane@example.com>"},
+		Sign: true,
+		SigningKey: "~/.ssh/bot.pub",
+		SigningFormat: "ssh",
+	}))
+}
+
+func TestCommitEnv(t *testing.T) {
+	assert.Empty(t, commitEnv(CommitOptions{}))
+	assert.Equal(t, []string{
+		"GIT_AUTHOR_NAME=Bot", "GIT_COMMITTER_NAME=Bot", "GIT_AUTHOR_EMAIL=bot@example.com", "GIT_COMMITTER_EMAIL=bot@example.com",
+	}, commitEnv(CommitOptions{AuthorName: "Bot", AuthorEmail: "bot@example.com"}))
+}
+
+func TestCommitAndPushInNewBranchAs(t *testing.T) {
+	// the identity of the environment is overridden by the one of the options
+	withIdentity(t)
+	dir := tempDir()
+	defer os.RemoveAll(dir)
+	origin := tempDir()
+	defer os.RemoveAll(origin)
+	g := Git(dir)
+	g.Exec("git", "init")
+	g.Exec("git", "init", "--bare", origin)
+	g.Exec("git", "remote", "add", "origin", origin)
+	g.Exec("touch", "file1.txt")
+
+	err := g.CommitAndPushInNewBranchTo("origin", "add-1", "Add a file", CommitOptions{
+		AuthorName: "Bot",
+		AuthorEmail: "bot@example.com",
+		SignOff: true,
+		CoAuthors: []string{"Jane <jane@example.com>"},
+	})
+	assert.Nil(t, err)
+
+	log, _ := Git(origin).Output("git", "log", "-1", "--format=%an <%ae>%n%B", "add-1")
+	assert.Equal(t, "Bot <bot@example.com>\nAdd a file\n\nCo-authored-by: Jane <jane@example.com>\nSigned-off-by: Bot <bot@example.com>\n\n", log)
+}
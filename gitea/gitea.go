@@ -0,0 +1,166 @@
+package gitea
+
+import (
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/httpapi"
+	"log"
+	"net/url"
+	"strconv"
+)
+
+const PROVIDER = "gitea"
+
+// Client calls the v1 API of a Gitea instance, authenticated with an access token
+type Client struct {
+	httpapi.Client
+}
+
+func NewClient(baseUrl string, token string) *Client {
+	return &Client{httpapi.Client{Provider: "Gitea", BaseUrl: baseUrl, AuthHeader: "Authorization", AuthValue: "token " + token}}
+}
+
+func (C *Client) apiUrl(path string) string {
+	return C.Url("api/v1/" + path)
+}
+
+type giteaRepo struct {
+	Name           string
+	Full_name      string
+	Ssh_url        string
+	Clone_url      string
+	Default_branch string
+	Archived       bool
+	Topics         []string
+	Language       string
+}
+
+func (C *Client) toRepo(repository giteaRepo) github.Repo {
+	apiUrl := C.apiUrl("repos/" + repository.Full_name)
+	return github.Repo{
+		Name: repository.Name,
+		FullName: C.Host() + "/" + repository.Full_name,
+		GitUrl: repository.Ssh_url,
+		HttpsUrl: repository.Clone_url,
+		PullsUrl: apiUrl + "/pulls",
+		ApiUrl: apiUrl,
+		DefaultBranch: repository.Default_branch,
+		Archived: repository.Archived,
+		Topics: repository.Topics,
+		Language: repository.Language,
+		Provider: PROVIDER,
+	}
+}
+
+// GetOrgRepos lists the repos of an organization
+func (C *Client) GetOrgRepos(organization string) ([]github.Repo, error) {
+	pageUrl := C.apiUrl("orgs/" + url.PathEscape(organization) + "/repos?limit=50")
+	repos := []github.Repo{}
+	for i := 1; pageUrl != ""; i++ {
+		log.Print("Loading page ", i)
+
+		page := make([]giteaRepo, 0)
+		nextUrl, err := C.Call("GET", pageUrl, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, repository := range page {
+			repos = append(repos, C.toRepo(repository))
+		}
+		pageUrl = nextUrl
+	}
+	return repos, nil
+}
+
+type giteaLabel struct {
+	Id   int
+	Name string
+}
+
+// labelIds resolves label names to the ids expected when creating a pull request
+func (C *Client) labelIds(repo github.Repo, names []string) ([]int, []string) {
+	ids := []int{}
+	warnings := []string{}
+	if len(names) == 0 {
+		return ids, warnings
+	}
+	labels := make([]giteaLabel, 0)
+	_, err := C.Call("GET", repo.ApiUrl + "/labels?limit=100", nil, &labels)
+	if err != nil {
+		return ids, []string{"Could not add labels: " + err.Error()}
+	}
+	for _, name := range names {
+		found := false
+		for _, label := range labels {
+			if label.Name == name {
+				ids = append(ids, label.Id)
+				found = true
+			}
+		}
+		if !found {
+			warnings = append(warnings, "Could not add label " + name + ": unknown label")
+		}
+	}
+	return ids, warnings
+}
+
+type giteaMilestone struct {
+	Id int
+}
+
+type giteaPullRequest struct {
+	Number   int
+	Url      string
+	Html_url string
+}
+
+// CreatePullRequest opens a pull request, then requests the reviews. Unknown labels or milestone, and failed review
+// requests, are reported as warnings.
+func (C *Client) CreatePullRequest(repo github.Repo, branch string, title string, options github.PullRequestOptions) (github.PullRequest, []string) {
+	labelIds, warnings := C.labelIds(repo, options.Labels)
+
+	base := repo.DefaultBranch
+	if base == "" {
+		base = "master"
+	}
+	if options.Draft {
+		title = "WIP: " + title
+	}
+	input := map[string]interface{}{
+		"head": branch,
+		"base": base,
+		"title": title,
+		"body": "Generated by foreachrepo",
+		"labels": labelIds,
+		"assignees": append([]string{}, options.Assignees...),
+	}
+	if options.Milestone != "" {
+		milestone := &giteaMilestone{}
+		_, err := C.Call("GET", repo.ApiUrl + "/milestones/" + url.PathEscape(options.Milestone), nil, milestone)
+		if err != nil {
+			warnings = append(warnings, "Could not set milestone: " + err.Error())
+		} else {
+			input["milestone"] = milestone.Id
+		}
+	}
+
+	result := &giteaPullRequest{}
+	_, err := C.Call("POST", repo.PullsUrl, input, result)
+	if err != nil {
+		panic(err)
+	}
+	apiUrl := repo.PullsUrl + "/" + strconv.Itoa(result.Number)
+
+	if len(options.Reviewers) > 0 || len(options.TeamReviewers) > 0 {
+		reviewers := map[string][]string{
+			"reviewers": append([]string{}, options.Reviewers...),
+			"team_reviewers": append([]string{}, options.TeamReviewers...),
+		}
+		var ignored interface{}
+		_, err := C.Call("POST", apiUrl + "/requested_reviewers", reviewers, &ignored)
+		if err != nil {
+			warnings = append(warnings, "Could not request reviewers: " + err.Error())
+		}
+	}
+
+	return github.PullRequest{Number: result.Number, Url: result.Html_url, ApiUrl: apiUrl}, warnings
+}
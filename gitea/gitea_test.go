@@ -0,0 +1,126 @@
+package gitea
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/httpapi"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// giteaStandIn serves the few Gitea v1 API endpoints used by foreachrepo, and records the pull requests and review
+// requests created
+type giteaStandIn struct {
+	server         *httptest.Server
+	pullRequests   []map[string]interface{}
+	reviewRequests []string
+}
+
+func newGiteaStandIn() *giteaStandIn {
+	standIn := &giteaStandIn{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/orgs/tools/repos", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(401)
+			fmt.Fprint(w, `{"message": "token is required"}`)
+			return
+		}
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", "<" + standIn.server.URL + `/api/v1/orgs/tools/repos?limit=50&page=2>; rel="next"`)
+			fmt.Fprint(w, `[{"name": "cli", "full_name": "tools/cli", "ssh_url": "git@gitea.example.com:tools/cli.git",
+				"default_branch": "main", "language": "Go"}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"name": "web", "full_name": "tools/web", "ssh_url": "git@gitea.example.com:tools/web.git",
+			"archived": true}]`)
+	})
+	mux.HandleFunc("/api/v1/repos/tools/cli/labels", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": 1, "name": "tech"}, {"id": 2, "name": "deps"}]`)
+	})
+	mux.HandleFunc("/api/v1/repos/tools/cli/milestones/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+		fmt.Fprint(w, `{"message": "milestone does not exist"}`)
+	})
+	mux.HandleFunc("/api/v1/repos/tools/cli/pulls", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		pullRequest := map[string]interface{}{}
+		json.Unmarshal(body, &pullRequest)
+		standIn.pullRequests = append(standIn.pullRequests, pullRequest)
+		w.WriteHeader(201)
+		fmt.Fprint(w, `{"number": 4, "html_url": "https://gitea.example.com/tools/cli/pulls/4"}`)
+	})
+	mux.HandleFunc("/api/v1/repos/tools/cli/pulls/4/requested_reviewers", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		standIn.reviewRequests = append(standIn.reviewRequests, string(body))
+		w.WriteHeader(201)
+		fmt.Fprint(w, `[]`)
+	})
+	standIn.server = httptest.NewServer(mux)
+	return standIn
+}
+
+func TestGetOrgRepos(t *testing.T) {
+	standIn := newGiteaStandIn()
+	defer standIn.server.Close()
+	client := NewClient(standIn.server.URL, "secret")
+
+	repos, err := client.GetOrgRepos("tools")
+	assert.Nil(t, err)
+	assert.Len(t, repos, 2)
+	assert.Equal(t, "cli", repos[0].Name)
+	assert.Equal(t, client.Host() + "/tools/cli", repos[0].FullName)
+	assert.Equal(t, "git@gitea.example.com:tools/cli.git", repos[0].GitUrl)
+	assert.Equal(t, standIn.server.URL + "/api/v1/repos/tools/cli/pulls", repos[0].PullsUrl)
+	assert.Equal(t, "main", repos[0].DefaultBranch)
+	assert.Equal(t, "Go", repos[0].Language)
+	assert.Equal(t, PROVIDER, repos[0].Provider)
+	assert.True(t, repos[1].Archived)
+}
+
+func TestGetOrgReposUnauthorized(t *testing.T) {
+	standIn := newGiteaStandIn()
+	defer standIn.server.Close()
+	client := NewClient(standIn.server.URL, "wrong")
+
+	repos, err := client.GetOrgRepos("tools")
+	assert.Nil(t, repos)
+	assert.Equal(t, 401, err.(*httpapi.ApiError).StatusCode)
+}
+
+func TestCreatePullRequest(t *testing.T) {
+	standIn := newGiteaStandIn()
+	defer standIn.server.Close()
+	client := NewClient(standIn.server.URL, "secret")
+	repos, _ := client.GetOrgRepos("tools")
+	options := github.PullRequestOptions{
+		Labels: []string{"deps", "unknown"},
+		Assignees: []string{"alice"},
+		Reviewers: []string{"bob"},
+		Milestone: "Q4",
+		Draft: true,
+	}
+
+	pull, warnings := client.CreatePullRequest(repos[0], "freeze-all-deps", "TECH Freeze all dependencies", options)
+	assert.Equal(t, 4, pull.Number)
+	assert.Equal(t, "https://gitea.example.com/tools/cli/pulls/4", pull.Url)
+	assert.Equal(t, standIn.server.URL + "/api/v1/repos/tools/cli/pulls/4", pull.ApiUrl)
+	assert.Len(t, warnings, 2)
+	assert.Contains(t, warnings[0], "unknown")
+	assert.Contains(t, warnings[1], "milestone")
+
+	assert.Len(t, standIn.pullRequests, 1)
+	bytes, _ := json.Marshal(standIn.pullRequests[0])
+	assert.JSONEq(t, `{
+		"head": "freeze-all-deps",
+		"base": "main",
+		"title": "WIP: TECH Freeze all dependencies",
+		"body": "Generated by foreachrepo",
+		"labels": [2],
+		"assignees": ["alice"]
+	}`, string(bytes))
+	assert.Equal(t, []string{`{"reviewers":["bob"],"team_reviewers":[]}`}, standIn.reviewRequests)
+}
@@ -0,0 +1,258 @@
+package github
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	REVIEW_APPROVED = "approved"
+	REVIEW_CHANGES_REQUESTED = "changes_requested"
+	REVIEW_REQUIRED = "review_required"
+)
+
+type githubSearchResult struct {
+	Total_count int
+	Items       []struct {
+		Pull_request struct {
+			Url string
+		}
+	}
+}
+
+type githubPullDetails struct {
+	Number          int
+	Node_id         string
+	Url             string
+	Html_url        string
+	State           string
+	Draft           bool
+	Merged          bool
+	Mergeable       *bool
+	Mergeable_state string
+	Created_at time.Time
+	Head       struct {
+		Ref  string
+		Sha  string
+		// the repo of the branch, a fork for the pull requests opened with -fork, null once deleted
+		Repo *struct {
+			Url string
+		}
+	}
+	Base       struct {
+		Repo struct {
+			Name string
+			Url  string
+		}
+	}
+}
+
+type githubCombinedStatus struct {
+	State string
+}
+
+type githubReview struct {
+	State string
+	User  struct {
+		Login string
+	}
+}
+
+// PullRequestStatus sums up where a campaign pull request stands
+type PullRequestStatus struct {
+	Repo           string
+	Number         int
+	Url            string
+	ApiUrl         string
+	RepoApiUrl     string
+	// the repo the branch is in, the fork for the pull requests opened from one, empty once deleted
+	HeadRepoApiUrl string
+	Branch         string
+	HeadSha        string
+	NodeId         string
+	State          string
+	Draft          bool
+	Merged         bool
+	Mergeable      *bool
+	MergeableState string
+	CiStatus       string
+	ReviewDecision string
+	CreatedAt      time.Time
+}
+
+func (P *PullRequestStatus) Age(now time.Time) time.Duration {
+	return now.Sub(P.CreatedAt)
+}
+
+// FindCampaignPullRequests searches the open and closed pull requests of the organization whose head is the
+// campaign branch, and returns their API urls
+func FindCampaignPullRequests(getter HttpGetter, organization string, branch string) ([]string, error) {
+	searchUrl := &url.URL{
+		Scheme:"https",
+		Host:"api.github.com",
+		Path:"search/issues",
+	}
+	urls := []string{}
+	for i := 1; ; i++ {
+		query := url.Values{}
+		query.Set("q", "is:pr org:" + organization + " head:" + branch)
+		query.Set("page", strconv.Itoa(i))
+		query.Set("per_page", "100")
+		searchUrl.RawQuery = query.Encode()
+
+		result := &githubSearchResult{}
+		err := getJson(getter, searchUrl.String(), result)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range result.Items {
+			urls = append(urls, item.Pull_request.Url)
+		}
+		if len(result.Items) == 0 || len(urls) >= result.Total_count {
+			return urls, nil
+		}
+	}
+}
+
+// reviewDecision computes the decision from the last review of every reviewer, as Github does
+func reviewDecision(reviews []githubReview) string {
+	latest := map[string]string{}
+	for _, review := range reviews {
+		if review.State == "APPROVED" || review.State == "CHANGES_REQUESTED" || review.State == "DISMISSED" {
+			latest[review.User.Login] = review.State
+		}
+	}
+	decision := REVIEW_REQUIRED
+	for _, state := range latest {
+		if state == "CHANGES_REQUESTED" {
+			return REVIEW_CHANGES_REQUESTED
+		}
+		if state == "APPROVED" {
+			decision = REVIEW_APPROVED
+		}
+	}
+	return decision
+}
+
+// GetPullRequestStatus loads the state, CI status and reviews of a pull request given its API url
+func GetPullRequestStatus(getter HttpGetter, pullUrl string) (*PullRequestStatus, error) {
+	details := &githubPullDetails{}
+	err := getJson(getter, pullUrl, details)
+	if err != nil {
+		return nil, err
+	}
+
+	combinedStatus := &githubCombinedStatus{}
+	err = getJson(getter, details.Base.Repo.Url + "/commits/" + details.Head.Sha + "/status", combinedStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	reviews := make([]githubReview, 0)
+	err = getJson(getter, pullUrl + "/reviews?per_page=100", &reviews)
+	if err != nil {
+		return nil, err
+	}
+
+	headRepoApiUrl := ""
+	if details.Head.Repo != nil {
+		headRepoApiUrl = details.Head.Repo.Url
+	}
+	return &PullRequestStatus{
+		Repo: details.Base.Repo.Name,
+		Number: details.Number,
+		Url: details.Html_url,
+		ApiUrl: details.Url,
+		RepoApiUrl: details.Base.Repo.Url,
+		HeadRepoApiUrl: headRepoApiUrl,
+		Branch: details.Head.Ref,
+		HeadSha: details.Head.Sha,
+		NodeId: details.Node_id,
+		State: details.State,
+		Draft: details.Draft,
+		Merged: details.Merged,
+		Mergeable: details.Mergeable,
+		MergeableState: details.Mergeable_state,
+		CiStatus: combinedStatus.State,
+		ReviewDecision: reviewDecision(reviews),
+		CreatedAt: details.Created_at,
+	}, nil
+}
+
+// ClosePullRequest closes a campaign pull request, after leaving a comment on it when one is given
+func ClosePullRequest(client HttpClient, status *PullRequestStatus, comment string) error {
+	var ignored interface{}
+	if comment != "" {
+		commentsUrl := status.RepoApiUrl + "/issues/" + strconv.Itoa(status.Number) + "/comments"
+		err := postJson(client, commentsUrl, map[string]string{"body": comment}, &ignored)
+		if err != nil {
+			return err
+		}
+	}
+	return patchJson(client, status.ApiUrl, map[string]string{"state": "closed"}, &ignored)
+}
+
+// DeleteBranch removes the head branch of a campaign pull request, from the fork it was pushed to if any. There is
+// nothing to delete once the head repo is gone.
+func DeleteBranch(deleter HttpDeleter, status *PullRequestStatus) error {
+	if status.HeadRepoApiUrl == "" {
+		return nil
+	}
+	return deleteResource(deleter, status.HeadRepoApiUrl + "/git/refs/heads/" + status.Branch)
+}
+
+const (
+	MERGE_NOW = "merge"
+	MERGE_AUTO = "auto-merge"
+	MERGE_SKIP = "skip"
+)
+
+// PlanMerge tells whether a campaign pull request can be merged right away, should be left to Github's auto-merge
+// because its required checks or reviews are still pending, or must be skipped, with the reason why
+func PlanMerge(status *PullRequestStatus) (string, string) {
+	switch {
+	case status.State != "open":
+		return MERGE_SKIP, "not open"
+	case status.Draft:
+		return MERGE_SKIP, "draft"
+	case status.ReviewDecision == REVIEW_CHANGES_REQUESTED:
+		return MERGE_SKIP, "changes requested"
+	}
+	switch status.MergeableState {
+	case "clean", "has_hooks", "unstable":
+		if status.Mergeable != nil && *status.Mergeable {
+			return MERGE_NOW, "required checks passed"
+		}
+		return MERGE_SKIP, "not mergeable"
+	case "blocked":
+		return MERGE_AUTO, "waiting for required checks or reviews"
+	case "behind":
+		return MERGE_SKIP, "behind its base branch"
+	case "dirty":
+		return MERGE_SKIP, "has conflicts"
+	default:
+		return MERGE_SKIP, "mergeability not known yet, try again later"
+	}
+}
+
+// MergePullRequest merges the pull request, as long as its head didn't move since its status was loaded
+func MergePullRequest(putter HttpPutter, status *PullRequestStatus, method string) error {
+	var ignored interface{}
+	input := map[string]string{"merge_method": method, "sha": status.HeadSha}
+	return putJson(putter, status.ApiUrl + "/merge", input, &ignored)
+}
+
+const ENABLE_AUTO_MERGE_MUTATION = `mutation($id: ID!, $method: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method}) {
+    clientMutationId
+  }
+}`
+
+// EnableAutoMerge asks Github to merge the pull request once its requirements are met. This fails on repos where
+// auto-merge isn't allowed.
+func EnableAutoMerge(poster HttpPoster, status *PullRequestStatus, method string) error {
+	variables := map[string]interface{}{"id": status.NodeId, "method": strings.ToUpper(method)}
+	return postGraphql(poster, ENABLE_AUTO_MERGE_MUTATION, variables, nil)
+}
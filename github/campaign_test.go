@@ -0,0 +1,185 @@
+package github
+
+import (
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindCampaignPullRequests(t *testing.T) {
+	client := &TestHttpClient{responses: map[string]string{
+		"GET https://api.github.com/search/issues?page=1&per_page=100&q=is%3Apr+org%3Aorg+head%3Afreeze-all-deps": `{
+			"total_count": 2,
+			"items": [
+				{"pull_request": {"url": "https://api.github.com/repos/org/repo1/pulls/1"}},
+				{"pull_request": {"url": "https://api.github.com/repos/org/repo2/pulls/7"}}
+			]
+		}`,
+	}}
+	urls, err := FindCampaignPullRequests(client, "org", "freeze-all-deps")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"https://api.github.com/repos/org/repo1/pulls/1",
+		"https://api.github.com/repos/org/repo2/pulls/7",
+	}, urls)
+}
+
+func TestFindCampaignPullRequestsError(t *testing.T) {
+	client := &TestHttpClient{}
+	urls, err := FindCampaignPullRequests(client, "org", "freeze-all-deps")
+	assert.Nil(t, urls)
+	assert.Contains(t, err.Error(), "404")
+}
+
+func TestGetPullRequestStatus(t *testing.T) {
+	client := &TestHttpClient{responses: map[string]string{
+		"GET https://api.github.com/repos/org/repo1/pulls/1": `{
+			"number": 1,
+			"url": "https://api.github.com/repos/org/repo1/pulls/1",
+			"html_url": "https://github.com/org/repo1/pull/1",
+			"state": "open",
+			"merged": false,
+			"created_at": "2016-10-01T10:00:00Z",
+			"head": {"ref": "freeze-all-deps", "sha": "abc123", "repo": {"url": "https://api.github.com/repos/bot/repo1"}},
+			"base": {"repo": {"name": "repo1", "url": "https://api.github.com/repos/org/repo1"}}
+		}`,
+		"GET https://api.github.com/repos/org/repo1/commits/abc123/status": `{"state": "success"}`,
+		"GET https://api.github.com/repos/org/repo1/pulls/1/reviews?per_page=100": `[
+			{"state": "CHANGES_REQUESTED", "user": {"login": "octocat"}},
+			{"state": "COMMENTED", "user": {"login": "octocat"}},
+			{"state": "APPROVED", "user": {"login": "octocat"}},
+			{"state": "COMMENTED", "user": {"login": "hubot"}}
+		]`,
+	}}
+	status, err := GetPullRequestStatus(client, "https://api.github.com/repos/org/repo1/pulls/1")
+	assert.Nil(t, err)
+	assert.Equal(t, "repo1", status.Repo)
+	assert.Equal(t, 1, status.Number)
+	assert.Equal(t, "https://github.com/org/repo1/pull/1", status.Url)
+	assert.Equal(t, "https://api.github.com/repos/org/repo1", status.RepoApiUrl)
+	assert.Equal(t, "https://api.github.com/repos/bot/repo1", status.HeadRepoApiUrl)
+	assert.Equal(t, "freeze-all-deps", status.Branch)
+	assert.Equal(t, "open", status.State)
+	assert.False(t, status.Merged)
+	assert.Equal(t, "success", status.CiStatus)
+	assert.Equal(t, REVIEW_APPROVED, status.ReviewDecision)
+	assert.Equal(t, 48 * time.Hour, status.Age(time.Date(2016, 10, 3, 10, 0, 0, 0, time.UTC)))
+}
+
+func TestReviewDecision(t *testing.T) {
+	assert.Equal(t, REVIEW_REQUIRED, reviewDecision([]githubReview{}))
+	changesRequested := []githubReview{{State: "APPROVED"}, {State: "CHANGES_REQUESTED"}}
+	changesRequested[0].User.Login = "octocat"
+	changesRequested[1].User.Login = "hubot"
+	assert.Equal(t, REVIEW_CHANGES_REQUESTED, reviewDecision(changesRequested))
+}
+
+var openStatus = &PullRequestStatus{
+	Repo: "repo1",
+	Number: 1,
+	ApiUrl: "https://api.github.com/repos/org/repo1/pulls/1",
+	RepoApiUrl: "https://api.github.com/repos/org/repo1",
+	HeadRepoApiUrl: "https://api.github.com/repos/org/repo1",
+	Branch: "freeze-all-deps",
+	State: "open",
+}
+
+func TestClosePullRequest(t *testing.T) {
+	client := &TestHttpClient{responses: map[string]string{
+		"POST https://api.github.com/repos/org/repo1/issues/1/comments": `{}`,
+		"PATCH https://api.github.com/repos/org/repo1/pulls/1": `{}`,
+	}}
+	err := ClosePullRequest(client, openStatus, "Wrong campaign, sorry")
+	assert.Nil(t, err)
+	assert.Len(t, client.requests, 2)
+	assert.JSONEq(t, `{"body": "Wrong campaign, sorry"}`, client.requests[0].Body)
+	assert.JSONEq(t, `{"state": "closed"}`, client.requests[1].Body)
+}
+
+func TestClosePullRequestWithoutComment(t *testing.T) {
+	client := &TestHttpClient{responses: map[string]string{
+		"PATCH https://api.github.com/repos/org/repo1/pulls/1": `{}`,
+	}}
+	err := ClosePullRequest(client, openStatus, "")
+	assert.Nil(t, err)
+	assert.Len(t, client.requests, 1)
+}
+
+func TestDeleteBranch(t *testing.T) {
+	client := &TestHttpClient{
+		responses: map[string]string{"DELETE https://api.github.com/repos/org/repo1/git/refs/heads/freeze-all-deps": ""},
+		statuses: map[string]int{"DELETE https://api.github.com/repos/org/repo1/git/refs/heads/freeze-all-deps": 204},
+	}
+	assert.Nil(t, DeleteBranch(client, openStatus))
+
+	client = &TestHttpClient{}
+	err := DeleteBranch(client, openStatus)
+	assert.Equal(t, 404, err.(*ApiError).StatusCode)
+}
+
+func TestDeleteBranchOfFork(t *testing.T) {
+	forkStatus := *openStatus
+	forkStatus.HeadRepoApiUrl = "https://api.github.com/repos/bot/repo1"
+	client := &TestHttpClient{
+		responses: map[string]string{"DELETE https://api.github.com/repos/bot/repo1/git/refs/heads/freeze-all-deps": ""},
+		statuses: map[string]int{"DELETE https://api.github.com/repos/bot/repo1/git/refs/heads/freeze-all-deps": 204},
+	}
+	assert.Nil(t, DeleteBranch(client, &forkStatus))
+	assert.Len(t, client.requests, 1)
+
+	forkStatus.HeadRepoApiUrl = ""
+	client = &TestHttpClient{}
+	assert.Nil(t, DeleteBranch(client, &forkStatus))
+	assert.Len(t, client.requests, 0)
+}
+
+func TestPlanMerge(t *testing.T) {
+	mergeable, notMergeable := true, false
+	cases := []struct {
+		status PullRequestStatus
+		action string
+	}{
+		{PullRequestStatus{State: "open", MergeableState: "clean", Mergeable: &mergeable}, MERGE_NOW},
+		{PullRequestStatus{State: "open", MergeableState: "unstable", Mergeable: &mergeable}, MERGE_NOW},
+		{PullRequestStatus{State: "open", MergeableState: "clean", Mergeable: &notMergeable}, MERGE_SKIP},
+		{PullRequestStatus{State: "open", MergeableState: "blocked", Mergeable: &mergeable}, MERGE_AUTO},
+		{PullRequestStatus{State: "open", MergeableState: "behind", Mergeable: &mergeable}, MERGE_SKIP},
+		{PullRequestStatus{State: "open", MergeableState: "dirty", Mergeable: &notMergeable}, MERGE_SKIP},
+		{PullRequestStatus{State: "open", MergeableState: "unknown"}, MERGE_SKIP},
+		{PullRequestStatus{State: "closed", MergeableState: "clean", Mergeable: &mergeable}, MERGE_SKIP},
+		{PullRequestStatus{State: "open", Draft: true, MergeableState: "clean", Mergeable: &mergeable}, MERGE_SKIP},
+		{PullRequestStatus{State: "open", MergeableState: "clean", Mergeable: &mergeable,
+			ReviewDecision: REVIEW_CHANGES_REQUESTED}, MERGE_SKIP},
+	}
+	for _, c := range cases {
+		action, reason := PlanMerge(&c.status)
+		assert.Equal(t, c.action, action, reason)
+		assert.NotEmpty(t, reason)
+	}
+}
+
+func TestMergePullRequest(t *testing.T) {
+	client := &TestHttpClient{responses: map[string]string{
+		"PUT https://api.github.com/repos/org/repo1/pulls/1/merge": `{"merged": true}`,
+	}}
+	status := *openStatus
+	status.HeadSha = "abc123"
+	assert.Nil(t, MergePullRequest(client, &status, "squash"))
+	assert.JSONEq(t, `{"merge_method": "squash", "sha": "abc123"}`, client.requests[0].Body)
+}
+
+func TestEnableAutoMerge(t *testing.T) {
+	client := &TestHttpClient{responses: map[string]string{
+		"POST https://api.github.com/graphql": `{"data": {"enablePullRequestAutoMerge": {"clientMutationId": null}}}`,
+	}}
+	status := *openStatus
+	status.NodeId = "PR_abc"
+	assert.Nil(t, EnableAutoMerge(client, &status, "squash"))
+	assert.Contains(t, client.requests[0].Body, `"variables":{"id":"PR_abc","method":"SQUASH"}`)
+
+	client = &TestHttpClient{responses: map[string]string{
+		"POST https://api.github.com/graphql": `{"errors": [{"message": "Auto merge is not allowed for this repository"}]}`,
+	}}
+	err := EnableAutoMerge(client, &status, "squash")
+	assert.Contains(t, err.Error(), "not allowed")
+}
@@ -0,0 +1,208 @@
+package github
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+type githubContent struct {
+	Type     string
+	Encoding string
+	Content  string
+	Sha      string
+}
+
+type githubBlob struct {
+	Sha      string
+	Encoding string
+	Content  string
+}
+
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// GetFile reads a file of the default branch of the repo with the contents API. A missing file fails with an error
+// satisfying os.IsNotExist.
+func GetFile(getter HttpGetter, repo Repo, path string) ([]byte, error) {
+	content := &githubContent{}
+	err := getJson(getter, repo.ApiUrl + "/contents/" + escapePath(path) + "?ref=" + url.QueryEscape(baseBranch(repo)), content)
+	if apiErr, ok := err.(*ApiError); ok && apiErr.StatusCode == 404 {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if content.Type != "file" {
+		return nil, errors.New(path + " is a " + content.Type + ", not a file")
+	}
+	// the contents API leaves out the files over 1MB, which are read as blobs
+	if content.Encoding != "base64" {
+		blob := &githubBlob{}
+		if err := getJson(getter, repo.ApiUrl + "/git/blobs/" + content.Sha, blob); err != nil {
+			return nil, err
+		}
+		content.Content = blob.Content
+	}
+	return base64.StdEncoding.DecodeString(strings.Replace(content.Content, "\n", "", -1))
+}
+
+// CommitAuthor is who the commits are from, the authenticated user by default
+type CommitAuthor struct {
+	Name  string
+	Email string
+}
+
+type githubRef struct {
+	Object struct {
+		Sha string
+	}
+}
+
+type githubCommit struct {
+	Sha  string
+	Tree struct {
+		Sha string
+	}
+}
+
+type githubSha struct {
+	Sha string
+}
+
+type githubTreeEntry struct {
+	Path string
+	Mode string
+	Type string
+	Sha  string
+}
+
+type githubTree struct {
+	Tree []githubTreeEntry
+}
+
+// DEFAULT_MODE is the mode of the files created, regular and non executable
+const DEFAULT_MODE = "100644"
+
+// fileModes returns the modes the files have in the tree, DEFAULT_MODE for the new ones. Only the trees of the dirs
+// holding the files are loaded.
+func fileModes(client HttpGetter, repo Repo, treeSha string, paths []string) (map[string]string, error) {
+	trees := map[string][]githubTreeEntry{}
+	var entriesOf func(dir string) ([]githubTreeEntry, error)
+	entriesOf = func(dir string) ([]githubTreeEntry, error) {
+		if entries, ok := trees[dir]; ok {
+			return entries, nil
+		}
+		sha := treeSha
+		if dir != "" {
+			parent, name := splitPath(dir)
+			parentEntries, err := entriesOf(parent)
+			if err != nil {
+				return nil, err
+			}
+			sha = ""
+			for _, entry := range parentEntries {
+				if entry.Path == name && entry.Type == "tree" {
+					sha = entry.Sha
+				}
+			}
+		}
+		tree := &githubTree{}
+		if sha != "" {
+			if err := getJson(client, repo.ApiUrl + "/git/trees/" + sha, tree); err != nil {
+				return nil, err
+			}
+		}
+		trees[dir] = tree.Tree
+		return tree.Tree, nil
+	}
+
+	modes := map[string]string{}
+	for _, path := range paths {
+		modes[path] = DEFAULT_MODE
+		dir, name := splitPath(path)
+		entries, err := entriesOf(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Path == name && entry.Type == "blob" {
+				modes[path] = entry.Mode
+			}
+		}
+	}
+	return modes, nil
+}
+
+// splitPath splits a slash separated path in its dir, empty at the root, and its base name
+func splitPath(path string) (string, string) {
+	slash := strings.LastIndex(path, "/")
+	if slash == -1 {
+		return "", path
+	}
+	return path[:slash], path[slash + 1:]
+}
+
+// CommitFiles commits the files on a new branch off the default branch with the git data API, without cloning the
+// repo. The files keep their mode, the new ones being regular, non executable, files, and are removed when their
+// content is nil.
+func CommitFiles(client HttpClient, repo Repo, branch string, message string, author *CommitAuthor, files map[string][]byte) error {
+	ref := &githubRef{}
+	err := getJson(client, repo.ApiUrl + "/git/ref/heads/" + escapePath(baseBranch(repo)), ref)
+	if err != nil {
+		return err
+	}
+	parent := &githubCommit{}
+	err = getJson(client, repo.ApiUrl + "/git/commits/" + ref.Object.Sha, parent)
+	if err != nil {
+		return err
+	}
+
+	paths := []string{}
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	modes, err := fileModes(client, repo, parent.Tree.Sha, paths)
+	if err != nil {
+		return err
+	}
+	entries := []map[string]interface{}{}
+	for _, path := range paths {
+		if files[path] == nil {
+			entries = append(entries, map[string]interface{}{"path": path, "mode": modes[path], "type": "blob", "sha": nil})
+			continue
+		}
+		blob := &githubSha{}
+		input := map[string]string{"content": base64.StdEncoding.EncodeToString(files[path]), "encoding": "base64"}
+		if err := postJson(client, repo.ApiUrl + "/git/blobs", input, blob); err != nil {
+			return err
+		}
+		entries = append(entries, map[string]interface{}{"path": path, "mode": modes[path], "type": "blob", "sha": blob.Sha})
+	}
+
+	tree := &githubSha{}
+	err = postJson(client, repo.ApiUrl + "/git/trees", map[string]interface{}{"base_tree": parent.Tree.Sha, "tree": entries}, tree)
+	if err != nil {
+		return err
+	}
+	commitInput := map[string]interface{}{"message": message, "tree": tree.Sha, "parents": []string{ref.Object.Sha}}
+	if author != nil {
+		commitInput["author"] = map[string]string{"name": author.Name, "email": author.Email}
+	}
+	commit := &githubSha{}
+	err = postJson(client, repo.ApiUrl + "/git/commits", commitInput, commit)
+	if err != nil {
+		return err
+	}
+	var ignored interface{}
+	return postJson(client, repo.ApiUrl + "/git/refs", map[string]string{"ref": "refs/heads/" + branch, "sha": commit.Sha}, &ignored)
+}
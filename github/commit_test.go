@@ -0,0 +1,76 @@
+package github
+
+import (
+	"os"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFile(t *testing.T) {
+	client := &TestHttpClient{responses: map[string]string{
+		"GET https://api.github.com/repos/org/repo1/contents/package.json?ref=master":
+			`{"type": "file", "encoding": "base64", "content": "eyJuYW1lIjog\nInJlcG8xIn0=\n", "sha": "abc"}`,
+		"GET https://api.github.com/repos/org/repo1/contents/big%20file.json?ref=master":
+			`{"type": "file", "encoding": "none", "content": "", "sha": "def"}`,
+		"GET https://api.github.com/repos/org/repo1/git/blobs/def": `{"sha": "def", "encoding": "base64", "content": "e30="}`,
+		"GET https://api.github.com/repos/org/repo1/contents/src?ref=master": `{"type": "dir"}`,
+	}}
+
+	content, err := GetFile(client, testRepo, "package.json")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name": "repo1"}`, string(content))
+
+	content, err = GetFile(client, testRepo, "big file.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "{}", string(content))
+
+	_, err = GetFile(client, testRepo, "CODEOWNERS")
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = GetFile(client, testRepo, "src")
+	assert.Equal(t, "src is a dir, not a file", err.Error())
+}
+
+func TestCommitFiles(t *testing.T) {
+	client := &TestHttpClient{responses: map[string]string{
+		"GET https://api.github.com/repos/org/repo1/git/ref/heads/master": `{"object": {"sha": "parent-sha"}}`,
+		"GET https://api.github.com/repos/org/repo1/git/commits/parent-sha": `{"sha": "parent-sha", "tree": {"sha": "base-tree-sha"}}`,
+		"GET https://api.github.com/repos/org/repo1/git/trees/base-tree-sha": `{"tree": [
+			{"path": ".github", "mode": "040000", "type": "tree", "sha": "github-tree-sha"},
+			{"path": ".travis.yml", "mode": "100644", "type": "blob", "sha": "travis-sha"},
+			{"path": "build.sh", "mode": "100755", "type": "blob", "sha": "build-sha"}
+		]}`,
+		"GET https://api.github.com/repos/org/repo1/git/trees/github-tree-sha": `{"tree": []}`,
+		"POST https://api.github.com/repos/org/repo1/git/blobs": `{"sha": "blob-sha"}`,
+		"POST https://api.github.com/repos/org/repo1/git/trees": `{"sha": "tree-sha"}`,
+		"POST https://api.github.com/repos/org/repo1/git/commits": `{"sha": "commit-sha"}`,
+		"POST https://api.github.com/repos/org/repo1/git/refs": `{"ref": "refs/heads/a-branch"}`,
+	}}
+
+	err := CommitFiles(client, testRepo, "a-branch", "Bump", &CommitAuthor{"Bot", "bot@example.com"}, map[string][]byte{
+		"package.json": []byte("{}"),
+		".github/CODEOWNERS": []byte("* @org/team"),
+		".travis.yml": nil,
+		"build.sh": []byte("#!/bin/sh\nmake"),
+	})
+	assert.Nil(t, err)
+	assert.Len(t, client.requests, 10)
+	assert.Equal(t, "https://api.github.com/repos/org/repo1/git/trees/base-tree-sha", client.requests[2].Url)
+	assert.Equal(t, "https://api.github.com/repos/org/repo1/git/trees/github-tree-sha", client.requests[3].Url)
+	assert.JSONEq(t, `{"content": "KiBAb3JnL3RlYW0=", "encoding": "base64"}`, client.requests[4].Body)
+	assert.JSONEq(t, `{"base_tree": "base-tree-sha", "tree": [
+		{"path": ".github/CODEOWNERS", "mode": "100644", "type": "blob", "sha": "blob-sha"},
+		{"path": ".travis.yml", "mode": "100644", "type": "blob", "sha": null},
+		{"path": "build.sh", "mode": "100755", "type": "blob", "sha": "blob-sha"},
+		{"path": "package.json", "mode": "100644", "type": "blob", "sha": "blob-sha"}
+	]}`, client.requests[7].Body)
+	assert.JSONEq(t, `{"message": "Bump", "tree": "tree-sha", "parents": ["parent-sha"],
+		"author": {"name": "Bot", "email": "bot@example.com"}}`, client.requests[8].Body)
+	assert.JSONEq(t, `{"ref": "refs/heads/a-branch", "sha": "commit-sha"}`, client.requests[9].Body)
+}
+
+func TestCommitFilesMissingBranch(t *testing.T) {
+	client := &TestHttpClient{}
+	err := CommitFiles(client, testRepo, "a-branch", "Bump", nil, map[string][]byte{"package.json": []byte("{}")})
+	assert.Equal(t, 404, err.(*ApiError).StatusCode)
+}
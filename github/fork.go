@@ -0,0 +1,49 @@
+package github
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// forkPollDelay spaces the checks of a fork being created, Github creating forks asynchronously
+var forkPollDelay = 2 * time.Second
+
+const FORK_POLL_ATTEMPTS = 15
+
+// Owner is the user or organization owning the repo, taken from its full name
+func (R Repo) Owner() string {
+	return strings.Split(R.FullName, "/")[0]
+}
+
+// ForkRepo forks the repo to the authenticated user's account, or returns the existing fork, and syncs the fork's
+// default branch with the upstream one
+func ForkRepo(client HttpClient, repo Repo) (Repo, error) {
+	description := &githubApiRepoDescription{}
+	err := postJson(client, repo.ApiUrl + "/forks", map[string]interface{}{}, description)
+	if err != nil {
+		return Repo{}, err
+	}
+	fork := description.toRepo()
+
+	for i := 0; ; i++ {
+		var ignored interface{}
+		err = getJson(client, fork.ApiUrl, &ignored)
+		if err == nil {
+			break
+		}
+		if i >= FORK_POLL_ATTEMPTS {
+			return Repo{}, errors.New("Fork " + fork.FullName + " still not available: " + err.Error())
+		}
+		log.Println(fork.FullName, " -> waiting for the fork to be created")
+		time.Sleep(forkPollDelay)
+	}
+
+	var ignored interface{}
+	err = postJson(client, fork.ApiUrl + "/merge-upstream", map[string]string{"branch": baseBranch(repo)}, &ignored)
+	if err != nil {
+		return Repo{}, errors.New("Could not sync fork " + fork.FullName + " with upstream: " + err.Error())
+	}
+	return fork, nil
+}
@@ -0,0 +1,72 @@
+package github
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	forkPollDelay = 0
+}
+
+func TestForkRepo(t *testing.T) {
+	client := &TestHttpClient{responses: map[string]string{
+		"POST https://api.github.com/repos/org/repo1/forks": `{
+			"name": "repo1",
+			"full_name": "bot/repo1",
+			"url": "https://api.github.com/repos/bot/repo1",
+			"ssh_url": "git@github.com:bot/repo1.git",
+			"pulls_url": "https://api.github.com/repos/bot/repo1/pulls{/number}"
+		}`,
+		"GET https://api.github.com/repos/bot/repo1": `{}`,
+		"POST https://api.github.com/repos/bot/repo1/merge-upstream": `{"merge_type": "fast-forward"}`,
+	}}
+	repo := testRepo
+	repo.FullName = "org/repo1"
+	repo.DefaultBranch = "main"
+
+	fork, err := ForkRepo(client, repo)
+	assert.Nil(t, err)
+	assert.Equal(t, "bot/repo1", fork.FullName)
+	assert.Equal(t, "bot", fork.Owner())
+	assert.Equal(t, "git@github.com:bot/repo1.git", fork.GitUrl)
+	assert.JSONEq(t, `{"branch": "main"}`, client.requests[2].Body)
+}
+
+func TestForkRepoSyncFailure(t *testing.T) {
+	client := &TestHttpClient{
+		responses: map[string]string{
+			"POST https://api.github.com/repos/org/repo1/forks": `{
+				"full_name": "bot/repo1",
+				"url": "https://api.github.com/repos/bot/repo1",
+				"pulls_url": "https://api.github.com/repos/bot/repo1/pulls{/number}"
+			}`,
+			"GET https://api.github.com/repos/bot/repo1": `{}`,
+			"POST https://api.github.com/repos/bot/repo1/merge-upstream": `{"message": "There are merge conflicts"}`,
+		},
+		statuses: map[string]int{"POST https://api.github.com/repos/bot/repo1/merge-upstream": 409},
+	}
+	_, err := ForkRepo(client, testRepo)
+	assert.Contains(t, err.Error(), "Could not sync")
+}
+
+func TestForkRepoNeverAvailable(t *testing.T) {
+	client := &TestHttpClient{responses: map[string]string{
+		"POST https://api.github.com/repos/org/repo1/forks": `{
+			"full_name": "bot/repo1",
+			"url": "https://api.github.com/repos/bot/repo1",
+			"pulls_url": "https://api.github.com/repos/bot/repo1/pulls{/number}"
+		}`,
+	}}
+	_, err := ForkRepo(client, testRepo)
+	assert.Contains(t, err.Error(), "still not available")
+	assert.Len(t, client.requests, FORK_POLL_ATTEMPTS + 2)
+}
+
+func TestCreatePullRequestFromFork(t *testing.T) {
+	client := &TestHttpClient{responses: map[string]string{
+		"POST https://api.github.com/repos/org/repo1/pulls": `{"number": 12}`,
+	}}
+	CreatePullRequest(client, testRepo, "a-branch", "A title", PullRequestOptions{HeadOwner: "bot"})
+	assert.Contains(t, client.requests[0].Body, `"head":"bot:a-branch"`)
+}
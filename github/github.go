@@ -1,6 +1,7 @@
 package github
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 	"fmt"
@@ -10,6 +11,8 @@ import (
 	"bytes"
 	"errors"
 	"io/ioutil"
+	"strings"
+	"github.com/transcovo/foreachrepo/httpapi"
 )
 
 type GitUserConfig struct {
@@ -18,9 +21,29 @@ type GitUserConfig struct {
 }
 
 type Repo struct {
-	Name     string
-	GitUrl   string
-	PullsUrl string
+	Name          string
+	FullName      string
+	GitUrl        string
+	// the HTTPS clone url, used with -clone-protocol https
+	HttpsUrl      string
+	PullsUrl      string
+	ApiUrl        string
+	DefaultBranch string
+	Archived      bool
+	Topics        []string
+	Language      string
+	// set for existing clones, which are worked on in place
+	LocalDir      string
+	// the provider hosting the repo, empty for Github
+	Provider      string
+}
+
+// DisplayName is the full name of the repo when known, its name otherwise
+func (R Repo) DisplayName() string {
+	if R.FullName != "" {
+		return R.FullName
+	}
+	return R.Name
 }
 
 type HttpGetter interface {
@@ -31,9 +54,56 @@ type HttpPoster interface {
 	Post(url string, body []byte) (*http.Response, error)
 }
 
+type HttpPatcher interface {
+	Patch(url string, body []byte) (*http.Response, error)
+}
+
+type HttpPutter interface {
+	Put(url string, body []byte) (*http.Response, error)
+}
+
+type HttpDeleter interface {
+	Delete(url string) (*http.Response, error)
+}
+
+type HttpClient interface {
+	HttpGetter
+	HttpPoster
+	HttpPatcher
+	HttpPutter
+	HttpDeleter
+}
+
+// ContextClient is a client whose requests can be bound to a context
+type ContextClient interface {
+	WithContext(ctx context.Context) HttpClient
+}
+
+// WithContext binds the requests of the client to the context when it supports it
+func WithContext(client HttpClient, ctx context.Context) HttpClient {
+	if contextClient, ok := client.(ContextClient); ok {
+		return contextClient.WithContext(ctx)
+	}
+	return client
+}
+
 type AuthHttpInterface struct {
 	Username string
 	Password string
+	// the http client and context the requests are sent with
+	httpapi.Client
+}
+
+// WithContext returns a copy of the interface whose requests are cancelled when the context is done
+func (A *AuthHttpInterface) WithContext(ctx context.Context) HttpClient {
+	copy := *A
+	copy.Client = *A.Client.WithContext(ctx)
+	return &copy
+}
+
+func (A *AuthHttpInterface) do(req *http.Request) (*http.Response, error) {
+	req.SetBasicAuth(A.Username, A.Password)
+	return A.Client.Do(req)
 }
 
 func (A *AuthHttpInterface) Get(url string) (*http.Response, error) {
@@ -41,16 +111,52 @@ func (A *AuthHttpInterface) Get(url string) (*http.Response, error) {
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(A.Username, A.Password)
-	return http.DefaultClient.Do(req)
+	return A.do(req)
 }
 func (A *AuthHttpInterface) Post(url string, body []byte) (*http.Response, error) {
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(A.Username, A.Password)
-	return http.DefaultClient.Do(req)
+	return A.do(req)
+}
+func (A *AuthHttpInterface) Patch(url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest("PATCH", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	return A.do(req)
+}
+func (A *AuthHttpInterface) Put(url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	return A.do(req)
+}
+func (A *AuthHttpInterface) Delete(url string) (*http.Response, error) {
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return A.do(req)
+}
+
+type ApiError struct {
+	StatusCode int
+	Detail     string
+}
+
+func (A *ApiError) Error() string {
+	return "Github API error " + strconv.Itoa(A.StatusCode) + " (" + A.Detail + ")"
+}
+
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := ioutil.ReadAll(resp.Body)
+		return &ApiError{resp.StatusCode, string(detail)}
+	}
+	return nil
 }
 
 func getJson(httpGetter HttpGetter, url string, target interface{}) error {
@@ -61,9 +167,8 @@ func getJson(httpGetter HttpGetter, url string, target interface{}) error {
 	}
 	defer r.Body.Close()
 
-	if r.StatusCode != 200 {
-		detail, _ := ioutil.ReadAll(r.Body)
-		panic("Non 200 return code: " + strconv.Itoa(r.StatusCode) + " (" + string(detail) + ")")
+	if err := checkStatus(r); err != nil {
+		return err
 	}
 
 	return json.NewDecoder(r.Body).Decode(target)
@@ -80,13 +185,105 @@ func postJson(httpPoster HttpPoster, url string, input interface{}, output inter
 		return err
 	}
 	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
 	return json.NewDecoder(resp.Body).Decode(output)
 }
 
+func patchJson(httpPatcher HttpPatcher, url string, input interface{}, output interface{}) error {
+	jsonStr, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpPatcher.Patch(url, jsonStr)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(output)
+}
+
+func putJson(httpPutter HttpPutter, url string, input interface{}, output interface{}) error {
+	jsonStr, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpPutter.Put(url, jsonStr)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(output)
+}
+
+const GRAPHQL_URL = "https://api.github.com/graphql"
+
+type GraphqlError struct {
+	Messages []string
+}
+
+func (G *GraphqlError) Error() string {
+	return "Github GraphQL error: " + strings.Join(G.Messages, ", ")
+}
+
+type graphqlResponse struct {
+	Data   json.RawMessage
+	Errors []struct {
+		Message string
+	}
+}
+
+// postGraphql runs a GraphQL query and decodes its data in output. Errors are reported with a 200 status code by
+// the GraphQL API, hence the check of the errors field.
+func postGraphql(httpPoster HttpPoster, query string, variables map[string]interface{}, output interface{}) error {
+	input := map[string]interface{}{"query": query, "variables": variables}
+	response := &graphqlResponse{}
+	err := postJson(httpPoster, GRAPHQL_URL, input, response)
+	if err != nil {
+		return err
+	}
+	if len(response.Errors) > 0 {
+		graphqlError := &GraphqlError{}
+		for _, e := range response.Errors {
+			graphqlError.Messages = append(graphqlError.Messages, e.Message)
+		}
+		return graphqlError
+	}
+	if output == nil {
+		return nil
+	}
+	return json.Unmarshal(response.Data, output)
+}
+
+func deleteResource(httpDeleter HttpDeleter, url string) error {
+	resp, err := httpDeleter.Delete(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkStatus(resp)
+}
+
 type githubApiRepoDescription struct {
-	Name      string
-	Ssh_url   string
-	Pulls_url string
+	Name           string
+	Full_name      string
+	Url            string
+	Ssh_url        string
+	Clone_url      string
+	Pulls_url      string
+	Default_branch string
+	Archived       bool
+	Topics         []string
+	Language       string
 }
 
 func removeSuffix(str string, suffix string) string {
@@ -97,66 +294,256 @@ func removeSuffix(str string, suffix string) string {
 	return str[:suffixStart]
 }
 
-func appendPageRepos(getter HttpGetter, repos *[]Repo, pageUrl *url.URL, i int) (int, error) {
-	query := pageUrl.Query()
-	query.Set("page", strconv.Itoa(i))
-	query.Set("per_page", "50")
-	pageUrl.RawQuery = query.Encode()
-
-	log.Print("Loading page ", i)
+func (D *githubApiRepoDescription) toRepo() Repo {
+	return Repo{
+		Name:D.Name,
+		FullName:D.Full_name,
+		GitUrl:D.Ssh_url,
+		HttpsUrl:D.Clone_url,
+		PullsUrl: removeSuffix(D.Pulls_url, "{/number}"),
+		ApiUrl:D.Url,
+		DefaultBranch:D.Default_branch,
+		Archived:D.Archived,
+		Topics:D.Topics,
+		Language:D.Language,
+	}
+}
 
-	page := make([]githubApiRepoDescription, 0)
-	err := getJson(getter, pageUrl.String(), &page)
+// getJsonPage decodes a page of a paginated list and returns the url of the next one
+func getJsonPage(httpGetter HttpGetter, url string, target interface{}) (string, error) {
+	r, err := httpGetter.Get(url)
 	if err != nil {
-		return 0, err
+		return "", err
 	}
+	defer r.Body.Close()
+
+	if err := checkStatus(r); err != nil {
+		return "", err
+	}
+	return httpapi.NextPageUrl(r.Header.Get("Link")), json.NewDecoder(r.Body).Decode(target)
+}
 
-	for _, repoDescription := range page {
+// listRepos loads every page of a repo list, following the Link headers from the first page url
+func listRepos(getter HttpGetter, pageUrl string) ([]Repo, error) {
+	repos := []Repo{}
+	for i := 1; pageUrl != ""; i++ {
+		log.Print("Loading page ", i)
 
-		repo := Repo{
-			Name:repoDescription.Name,
-			GitUrl:repoDescription.Ssh_url,
-			PullsUrl: removeSuffix(repoDescription.Pulls_url, "{/number}"),
+		page := make([]githubApiRepoDescription, 0)
+		nextUrl, err := getJsonPage(getter, pageUrl, &page)
+		if err != nil {
+			return nil, err
 		}
 
-		*repos = append(*repos, repo)
+		for _, repoDescription := range page {
+			repos = append(repos, repoDescription.toRepo())
+		}
+		pageUrl = nextUrl
 	}
-	return len(page), nil
+	return repos, nil
 }
 
-func GetReposList(getter HttpGetter, organization string) ([]Repo, error) {
+func apiUrl(path string, query url.Values) string {
+	query.Set("per_page", "100")
 	pageUrl := &url.URL{
 		Scheme:"https",
 		Host:"api.github.com",
-		Path:fmt.Sprintf("orgs/%v/repos", organization),
+		Path:path,
+		RawQuery:query.Encode(),
 	}
+	return pageUrl.String()
+}
+
+func GetReposList(getter HttpGetter, organization string) ([]Repo, error) {
+	return listRepos(getter, apiUrl(fmt.Sprintf("orgs/%v/repos", organization), url.Values{}))
+}
+
+func GetUserReposList(getter HttpGetter, user string) ([]Repo, error) {
+	return listRepos(getter, apiUrl(fmt.Sprintf("users/%v/repos", user), url.Values{}))
+}
+
+func GetTeamReposList(getter HttpGetter, organization string, team string) ([]Repo, error) {
+	return listRepos(getter, apiUrl(fmt.Sprintf("orgs/%v/teams/%v/repos", organization, team), url.Values{}))
+}
+
+// GetAuthenticatedUserReposList lists the repos the authenticated user owns, collaborates on or can access
+// through an organization
+func GetAuthenticatedUserReposList(getter HttpGetter) ([]Repo, error) {
+	return listRepos(getter, apiUrl("user/repos", url.Values{}))
+}
+
+type githubCodeSearchResult struct {
+	Items []struct {
+		Repository githubApiRepoDescription
+	}
+}
+
+// SearchCodeReposList lists the repos having files matching a code search query, like "org:transcovo filename:.nvmrc".
+// The search results only describe the repos partially, without their clone urls nor default branch, so every repo
+// is loaded once.
+func SearchCodeReposList(getter HttpGetter, query string) ([]Repo, error) {
+	pageUrl := apiUrl("search/code", url.Values{"q": []string{query}})
 	repos := []Repo{}
-	for i := 1; ; i++ {
-		count, err := appendPageRepos(getter, &repos, pageUrl, i)
+	seen := map[string]bool{}
+	for i := 1; pageUrl != ""; i++ {
+		log.Print("Loading search page ", i)
+
+		result := &githubCodeSearchResult{}
+		nextUrl, err := getJsonPage(getter, pageUrl, result)
 		if err != nil {
 			return nil, err
 		}
-		if count == 0 {
-			return repos, nil
+		for _, item := range result.Items {
+			if seen[item.Repository.Full_name] {
+				continue
+			}
+			seen[item.Repository.Full_name] = true
+			description := &githubApiRepoDescription{}
+			if err := getJson(getter, item.Repository.Url, description); err != nil {
+				return nil, err
+			}
+			repos = append(repos, description.toRepo())
 		}
+		pageUrl = nextUrl
 	}
+	return repos, nil
+}
+
+// UniqueRepos removes the repos listed several times, by full name, keeping the first occurrence
+func UniqueRepos(repos []Repo) []Repo {
+	unique := []Repo{}
+	seen := map[string]bool{}
+	for _, repo := range repos {
+		key := repo.DisplayName()
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, repo)
+		}
+	}
+	return unique
 }
 
 type githubPullDescription struct {
+	Number   int
+	Url      string
 	Html_url string
 }
 
-func CreatePullRequest(poster HttpPoster, repo Repo, branch string, title string) string {
-	input := map[string]string{
+// PullRequest identifies a pull request opened by foreachrepo
+type PullRequest struct {
+	Number int
+	Url    string
+	ApiUrl string
+}
+
+type githubMilestoneDescription struct {
+	Number int
+	Title  string
+}
+
+// PullRequestOptions holds everything set on a pull request after its creation
+type PullRequestOptions struct {
+	Labels        []string
+	Reviewers     []string
+	TeamReviewers []string
+	Assignees     []string
+	Milestone     string
+	Draft         bool
+	// set when the branch was pushed to a fork rather than to the repo itself
+	HeadOwner     string
+}
+
+// findMilestone resolves a milestone given either by number or by title
+func findMilestone(getter HttpGetter, repo Repo, milestone string) (int, error) {
+	if number, err := strconv.Atoi(milestone); err == nil {
+		return number, nil
+	}
+	milestones := make([]githubMilestoneDescription, 0)
+	err := getJson(getter, repo.ApiUrl + "/milestones?state=open&per_page=100", &milestones)
+	if err != nil {
+		return 0, err
+	}
+	for _, description := range milestones {
+		if description.Title == milestone {
+			return description.Number, nil
+		}
+	}
+	return 0, errors.New("Milestone " + milestone + " not found")
+}
+
+// decoratePullRequest applies labels, assignees, milestone and reviewers to a freshly created pull request.
+// Failures are returned as warnings since the pull request itself exists at this point.
+func decoratePullRequest(client HttpClient, repo Repo, number int, options PullRequestOptions) []string {
+	warnings := []string{}
+	issueUrl := repo.ApiUrl + "/issues/" + strconv.Itoa(number)
+	var ignored interface{}
+
+	if len(options.Labels) > 0 {
+		err := postJson(client, issueUrl + "/labels", map[string][]string{"labels": options.Labels}, &ignored)
+		if err != nil {
+			warnings = append(warnings, "Could not add labels: " + err.Error())
+		}
+	}
+	if len(options.Assignees) > 0 {
+		err := postJson(client, issueUrl + "/assignees", map[string][]string{"assignees": options.Assignees}, &ignored)
+		if err != nil {
+			warnings = append(warnings, "Could not add assignees: " + err.Error())
+		}
+	}
+	if options.Milestone != "" {
+		milestone, err := findMilestone(client, repo, options.Milestone)
+		if err == nil {
+			err = patchJson(client, issueUrl, map[string]int{"milestone": milestone}, &ignored)
+		}
+		if err != nil {
+			warnings = append(warnings, "Could not set milestone: " + err.Error())
+		}
+	}
+	if len(options.Reviewers) > 0 || len(options.TeamReviewers) > 0 {
+		input := map[string][]string{
+			"reviewers": nonNil(options.Reviewers),
+			"team_reviewers": nonNil(options.TeamReviewers),
+		}
+		err := postJson(client, repo.PullsUrl + "/" + strconv.Itoa(number) + "/requested_reviewers", input, &ignored)
+		if err != nil {
+			warnings = append(warnings, "Could not request reviewers: " + err.Error())
+		}
+	}
+	return warnings
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func baseBranch(repo Repo) string {
+	if repo.DefaultBranch != "" {
+		return repo.DefaultBranch
+	}
+	return "master"
+}
+
+// CreatePullRequest opens the pull request and returns it along with the warnings raised while decorating it
+func CreatePullRequest(client HttpClient, repo Repo, branch string, title string, options PullRequestOptions) (PullRequest, []string) {
+	head := branch
+	if options.HeadOwner != "" {
+		head = options.HeadOwner + ":" + branch
+	}
+	input := map[string]interface{}{
 		"title": title,
 		"body": "Generated by foreachrepo",
-		"head": branch,
-		"base": "master",
+		"head": head,
+		"base": baseBranch(repo),
+		"draft": options.Draft,
 	}
 	result := &githubPullDescription{}
-	err := postJson(poster, repo.PullsUrl, input, result)
+	err := postJson(client, repo.PullsUrl, input, result)
 	if err != nil {
 		panic(err)
 	}
-	return result.Html_url
+	warnings := decoratePullRequest(client, repo, result.Number, options)
+	return PullRequest{result.Number, result.Html_url, result.Url}, warnings
 }
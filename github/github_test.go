@@ -27,16 +27,21 @@ func (cb *ClosingBuffer) Close() error {
 
 type TestHttpGetterSuccess struct {
 	responses map[string]string
+	links     map[string]string
 }
 
 func (getter TestHttpGetterSuccess) Get(url string) (*http.Response, error) {
-	response := &http.Response{}
+	response := &http.Response{StatusCode: 200}
 
 	responseString, ok := getter.responses[url]
 	if !ok {
 		panic(errors.New("Unexpected url: " + url))
 	}
 
+	response.Header = http.Header{}
+	if link, ok := getter.links[url]; ok {
+		response.Header.Set("Link", link)
+	}
 	response.Body = &ClosingBuffer{bytes.NewBufferString(responseString)}
 	return response, nil
 }
@@ -54,8 +59,14 @@ func TestGetReposError(t *testing.T) {
 func TestGetReposSuccess(t *testing.T) {
 	page1 := `[{
 		"name": "repo1",
+		"full_name": "org/repo1",
 		"ssh_url": "git@github.com:org/repo1.git",
-		"pulls_url": "http://api.github.com/repos/org/repo1/pulls{/number}"
+		"clone_url": "https://github.com/org/repo1.git",
+		"pulls_url": "http://api.github.com/repos/org/repo1/pulls{/number}",
+		"default_branch": "main",
+		"archived": true,
+		"topics": ["nodejs", "api"],
+		"language": "JavaScript"
 	}, {
 		"name": "repo2",
 		"ssh_url": "git@github.com:org/repo2.git",
@@ -72,25 +83,36 @@ func TestGetReposSuccess(t *testing.T) {
 		"pulls_url": "http://api.github.com/repos/org/repo4/pulls{/number}"
 	}]`
 
-	page3 := "[]"
-
 	responses := map[string]string{
-		"https://api.github.com/orgs/org/repos?page=1&per_page=50": page1,
-		"https://api.github.com/orgs/org/repos?page=2&per_page=50": page2,
-		"https://api.github.com/orgs/org/repos?page=3&per_page=50": page3,
+		"https://api.github.com/orgs/org/repos?per_page=100": page1,
+		"https://api.github.com/organizations/1/repos?page=2&per_page=100": page2,
+	}
+	links := map[string]string{
+		"https://api.github.com/orgs/org/repos?per_page=100": `<https://api.github.com/organizations/1/repos?page=2&per_page=100>; rel="next", ` +
+			`<https://api.github.com/organizations/1/repos?page=2&per_page=100>; rel="last"`,
+		"https://api.github.com/organizations/1/repos?page=2&per_page=100": `<https://api.github.com/organizations/1/repos?page=1&per_page=100>; rel="prev", ` +
+			`<https://api.github.com/organizations/1/repos?page=1&per_page=100>; rel="first"`,
 	}
 
-	getter := &TestHttpGetterSuccess{responses: responses}
+	getter := &TestHttpGetterSuccess{responses: responses, links: links}
 	repos, err := GetReposList(getter, "org")
 	assert.Nil(t, err)
 	assert.Len(t, repos, 4)
 	assert.Equal(t, "git@github.com:org/repo1.git", repos[0].GitUrl)
+	assert.Equal(t, "https://github.com/org/repo1.git", repos[0].HttpsUrl)
 	assert.Equal(t, "repo1", repos[0].Name)
 	assert.Equal(t, "http://api.github.com/repos/org/repo1/pulls", repos[0].PullsUrl)
+	assert.Equal(t, "org/repo1", repos[0].FullName)
+	assert.Equal(t, "main", repos[0].DefaultBranch)
+	assert.True(t, repos[0].Archived)
+	assert.Equal(t, []string{"nodejs", "api"}, repos[0].Topics)
+	assert.Equal(t, "JavaScript", repos[0].Language)
 	
 	assert.Equal(t, "git@github.com:org/repo2.git", repos[1].GitUrl)
 	assert.Equal(t, "repo2", repos[1].Name)
 	assert.Equal(t, "http://api.github.com/repos/org/repo2/pulls", repos[1].PullsUrl)
+	assert.Equal(t, "", repos[1].DefaultBranch)
+	assert.False(t, repos[1].Archived)
 	
 	assert.Equal(t, "git@github.com:org/repo3.git", repos[2].GitUrl)
 	assert.Equal(t, "repo3", repos[2].Name)
@@ -100,3 +122,205 @@ func TestGetReposSuccess(t *testing.T) {
 	assert.Equal(t, "repo4", repos[3].Name)
 	assert.Equal(t, "http://api.github.com/repos/org/repo4/pulls", repos[3].PullsUrl)
 }
+
+type recordedRequest struct {
+	Method string
+	Url    string
+	Body   string
+}
+
+// TestHttpClient answers every request with the configured response for "METHOD url", and 404 otherwise
+type TestHttpClient struct {
+	responses map[string]string
+	statuses  map[string]int
+	requests  []recordedRequest
+}
+
+func (client *TestHttpClient) respond(method string, url string, body []byte) (*http.Response, error) {
+	key := method + " " + url
+	client.requests = append(client.requests, recordedRequest{method, url, string(body)})
+	response := &http.Response{StatusCode: 404}
+	responseString, ok := client.responses[key]
+	if ok {
+		response.StatusCode = 200
+	}
+	if status, ok := client.statuses[key]; ok {
+		response.StatusCode = status
+	}
+	response.Body = &ClosingBuffer{bytes.NewBufferString(responseString)}
+	return response, nil
+}
+
+func (client *TestHttpClient) Get(url string) (*http.Response, error) {
+	return client.respond("GET", url, nil)
+}
+
+func (client *TestHttpClient) Post(url string, body []byte) (*http.Response, error) {
+	return client.respond("POST", url, body)
+}
+
+func (client *TestHttpClient) Patch(url string, body []byte) (*http.Response, error) {
+	return client.respond("PATCH", url, body)
+}
+
+func (client *TestHttpClient) Put(url string, body []byte) (*http.Response, error) {
+	return client.respond("PUT", url, body)
+}
+
+func (client *TestHttpClient) Delete(url string) (*http.Response, error) {
+	return client.respond("DELETE", url, nil)
+}
+
+var testRepo = Repo{
+	Name: "repo1",
+	GitUrl: "git@github.com:org/repo1.git",
+	PullsUrl: "https://api.github.com/repos/org/repo1/pulls",
+	ApiUrl: "https://api.github.com/repos/org/repo1",
+}
+
+func TestCreatePullRequestDefaultBranch(t *testing.T) {
+	client := &TestHttpClient{responses: map[string]string{
+		"POST https://api.github.com/repos/org/repo1/pulls": `{"number": 12}`,
+	}}
+	repo := testRepo
+	repo.DefaultBranch = "main"
+	CreatePullRequest(client, repo, "a-branch", "A title", PullRequestOptions{})
+	assert.Contains(t, client.requests[0].Body, `"base":"main"`)
+}
+
+func TestCreatePullRequest(t *testing.T) {
+	client := &TestHttpClient{responses: map[string]string{
+		"POST https://api.github.com/repos/org/repo1/pulls": `{"number": 12, "url": "https://api.github.com/repos/org/repo1/pulls/12", "html_url": "https://github.com/org/repo1/pull/12"}`,
+	}}
+	pull, warnings := CreatePullRequest(client, testRepo, "a-branch", "A title", PullRequestOptions{Draft: true})
+	assert.Equal(t, 12, pull.Number)
+	assert.Equal(t, "https://github.com/org/repo1/pull/12", pull.Url)
+	assert.Equal(t, "https://api.github.com/repos/org/repo1/pulls/12", pull.ApiUrl)
+	assert.Empty(t, warnings)
+	assert.Len(t, client.requests, 1)
+	assert.JSONEq(t, `{"title": "A title", "body": "Generated by foreachrepo", "head": "a-branch", "base": "master", "draft": true}`, client.requests[0].Body)
+}
+
+func TestCreatePullRequestFailure(t *testing.T) {
+	client := &TestHttpClient{
+		responses: map[string]string{"POST https://api.github.com/repos/org/repo1/pulls": `{"message": "Validation Failed"}`},
+		statuses: map[string]int{"POST https://api.github.com/repos/org/repo1/pulls": 422},
+	}
+	assert.Panics(t, func() {
+		CreatePullRequest(client, testRepo, "a-branch", "A title", PullRequestOptions{})
+	})
+}
+
+func TestCreatePullRequestDecorations(t *testing.T) {
+	client := &TestHttpClient{responses: map[string]string{
+		"POST https://api.github.com/repos/org/repo1/pulls": `{"number": 12, "url": "https://api.github.com/repos/org/repo1/pulls/12", "html_url": "https://github.com/org/repo1/pull/12"}`,
+		"POST https://api.github.com/repos/org/repo1/issues/12/labels": `[]`,
+		"POST https://api.github.com/repos/org/repo1/issues/12/assignees": `{}`,
+		"GET https://api.github.com/repos/org/repo1/milestones?state=open&per_page=100": `[{"number": 3, "title": "Q4"}]`,
+		"PATCH https://api.github.com/repos/org/repo1/issues/12": `{}`,
+		"POST https://api.github.com/repos/org/repo1/pulls/12/requested_reviewers": `{}`,
+	}}
+	options := PullRequestOptions{
+		Labels: []string{"tech", "deps"},
+		Reviewers: []string{"octocat"},
+		TeamReviewers: []string{"backend"},
+		Assignees: []string{"octocat"},
+		Milestone: "Q4",
+	}
+	pull, warnings := CreatePullRequest(client, testRepo, "a-branch", "A title", options)
+	assert.Equal(t, "https://github.com/org/repo1/pull/12", pull.Url)
+	assert.Empty(t, warnings)
+	assert.Len(t, client.requests, 6)
+	assert.JSONEq(t, `{"labels": ["tech", "deps"]}`, client.requests[1].Body)
+	assert.JSONEq(t, `{"assignees": ["octocat"]}`, client.requests[2].Body)
+	assert.JSONEq(t, `{"milestone": 3}`, client.requests[4].Body)
+	assert.JSONEq(t, `{"reviewers": ["octocat"], "team_reviewers": ["backend"]}`, client.requests[5].Body)
+}
+
+func TestCreatePullRequestPartialFailure(t *testing.T) {
+	client := &TestHttpClient{
+		responses: map[string]string{
+			"POST https://api.github.com/repos/org/repo1/pulls": `{"number": 12, "url": "https://api.github.com/repos/org/repo1/pulls/12", "html_url": "https://github.com/org/repo1/pull/12"}`,
+			"POST https://api.github.com/repos/org/repo1/issues/12/labels": `[]`,
+			"POST https://api.github.com/repos/org/repo1/pulls/12/requested_reviewers": `{"message": "Reviews may only be requested from collaborators"}`,
+		},
+		statuses: map[string]int{
+			"POST https://api.github.com/repos/org/repo1/pulls/12/requested_reviewers": 422,
+		},
+	}
+	options := PullRequestOptions{
+		Labels: []string{"tech"},
+		Reviewers: []string{"nobody"},
+		Milestone: "7",
+	}
+	pull, warnings := CreatePullRequest(client, testRepo, "a-branch", "A title", options)
+	assert.Equal(t, "https://github.com/org/repo1/pull/12", pull.Url)
+	assert.Len(t, warnings, 2)
+	assert.Contains(t, warnings[0], "milestone")
+	assert.Contains(t, warnings[1], "collaborators")
+}
+
+const SINGLE_REPO_PAGE = `[{
+	"name": "repo1",
+	"full_name": "org/repo1",
+	"ssh_url": "git@github.com:org/repo1.git",
+	"pulls_url": "http://api.github.com/repos/org/repo1/pulls{/number}"
+}]`
+
+func TestGetReposListSources(t *testing.T) {
+	getter := &TestHttpGetterSuccess{responses: map[string]string{
+		"https://api.github.com/users/octocat/repos?per_page=100": SINGLE_REPO_PAGE,
+		"https://api.github.com/orgs/org/teams/backend/repos?per_page=100": SINGLE_REPO_PAGE,
+		"https://api.github.com/user/repos?per_page=100": SINGLE_REPO_PAGE,
+	}}
+
+	repos, err := GetUserReposList(getter, "octocat")
+	assert.Nil(t, err)
+	assert.Equal(t, "org/repo1", repos[0].FullName)
+
+	repos, err = GetTeamReposList(getter, "org", "backend")
+	assert.Nil(t, err)
+	assert.Equal(t, "org/repo1", repos[0].FullName)
+
+	repos, err = GetAuthenticatedUserReposList(getter)
+	assert.Nil(t, err)
+	assert.Equal(t, "org/repo1", repos[0].FullName)
+}
+
+func TestSearchCodeReposList(t *testing.T) {
+	item := `{"repository": {
+		"name": "repo1",
+		"full_name": "org/repo1",
+		"url": "https://api.github.com/repos/org/repo1"
+	}}`
+	getter := &TestHttpGetterSuccess{responses: map[string]string{
+		"https://api.github.com/search/code?per_page=100&q=org%3Aorg+filename%3A.nvmrc": `{"items": [` + item + `, ` + item + `]}`,
+		"https://api.github.com/repos/org/repo1": `{
+			"name": "repo1",
+			"full_name": "org/repo1",
+			"url": "https://api.github.com/repos/org/repo1",
+			"ssh_url": "git@github.com:org/repo1.git",
+			"clone_url": "https://github.com/org/repo1.git",
+			"pulls_url": "https://api.github.com/repos/org/repo1/pulls{/number}",
+			"default_branch": "main"
+		}`,
+	}}
+	repos, err := SearchCodeReposList(getter, "org:org filename:.nvmrc")
+	assert.Nil(t, err)
+	assert.Len(t, repos, 1)
+	assert.Equal(t, "git@github.com:org/repo1.git", repos[0].GitUrl)
+	assert.Equal(t, "https://github.com/org/repo1.git", repos[0].HttpsUrl)
+	assert.Equal(t, "main", repos[0].DefaultBranch)
+}
+
+func TestUniqueRepos(t *testing.T) {
+	repos := UniqueRepos([]Repo{
+		{Name: "repo1", FullName: "org/repo1"},
+		{Name: "repo1", FullName: "other/repo1"},
+		{Name: "repo1", FullName: "org/repo1", GitUrl: "duplicate"},
+	})
+	assert.Len(t, repos, 2)
+	assert.Equal(t, "org/repo1", repos[0].DisplayName())
+	assert.Equal(t, "", repos[0].GitUrl)
+	assert.Equal(t, "other/repo1", repos[1].DisplayName())
+}
@@ -0,0 +1,106 @@
+package github
+
+import (
+	"log"
+)
+
+const REPOS_QUERY = `query($organization: String!, $cursor: String) {
+  organization(login: $organization) {
+    repositories(first: 100, after: $cursor) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        name
+        nameWithOwner
+        sshUrl
+        url
+        isArchived
+        defaultBranchRef { name }
+        primaryLanguage { name }
+        repositoryTopics(first: 20) { nodes { topic { name } } }
+      }
+    }
+  }
+}`
+
+type graphqlRepo struct {
+	Name             string
+	NameWithOwner    string
+	SshUrl           string
+	Url              string
+	IsArchived       bool
+	DefaultBranchRef *struct {
+		Name string
+	}
+	PrimaryLanguage  *struct {
+		Name string
+	}
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct {
+				Name string
+			}
+		}
+	}
+}
+
+type graphqlReposPage struct {
+	Organization struct {
+		Repositories struct {
+			PageInfo struct {
+				HasNextPage bool
+				EndCursor   string
+			}
+			Nodes    []graphqlRepo
+		}
+	}
+}
+
+func (G *graphqlRepo) toRepo() Repo {
+	apiUrl := "https://api.github.com/repos/" + G.NameWithOwner
+	repo := Repo{
+		Name: G.Name,
+		FullName: G.NameWithOwner,
+		GitUrl: G.SshUrl,
+		PullsUrl: apiUrl + "/pulls",
+		ApiUrl: apiUrl,
+		Archived: G.IsArchived,
+		Topics: []string{},
+	}
+	if G.Url != "" {
+		repo.HttpsUrl = G.Url + ".git"
+	}
+	if G.DefaultBranchRef != nil {
+		repo.DefaultBranch = G.DefaultBranchRef.Name
+	}
+	if G.PrimaryLanguage != nil {
+		repo.Language = G.PrimaryLanguage.Name
+	}
+	for _, node := range G.RepositoryTopics.Nodes {
+		repo.Topics = append(repo.Topics, node.Topic.Name)
+	}
+	return repo
+}
+
+// GetReposListGraphql lists the repos of the organization like GetReposList, with their metadata, through the
+// GraphQL API which needs a single call per 100 repos
+func GetReposListGraphql(poster HttpPoster, organization string) ([]Repo, error) {
+	repos := []Repo{}
+	variables := map[string]interface{}{"organization": organization, "cursor": nil}
+	for i := 1; ; i++ {
+		log.Print("Loading page ", i)
+
+		page := &graphqlReposPage{}
+		err := postGraphql(poster, REPOS_QUERY, variables, page)
+		if err != nil {
+			return nil, err
+		}
+		repositories := page.Organization.Repositories
+		for _, node := range repositories.Nodes {
+			repos = append(repos, node.toRepo())
+		}
+		if !repositories.PageInfo.HasNextPage {
+			return repos, nil
+		}
+		variables["cursor"] = repositories.PageInfo.EndCursor
+	}
+}
@@ -0,0 +1,75 @@
+package github
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHttpPosterSequence answers the posted requests with the responses in order
+type TestHttpPosterSequence struct {
+	responses []string
+	bodies    []string
+}
+
+func (poster *TestHttpPosterSequence) Post(url string, body []byte) (*http.Response, error) {
+	poster.bodies = append(poster.bodies, string(body))
+	response := &http.Response{StatusCode: 200}
+	response.Body = &ClosingBuffer{bytes.NewBufferString(poster.responses[0])}
+	poster.responses = poster.responses[1:]
+	return response, nil
+}
+
+func TestGetReposListGraphql(t *testing.T) {
+	poster := &TestHttpPosterSequence{responses: []string{`{"data": {"organization": {"repositories": {
+		"pageInfo": {"hasNextPage": true, "endCursor": "cursor1"},
+		"nodes": [{
+			"name": "repo1",
+			"nameWithOwner": "org/repo1",
+			"sshUrl": "git@github.com:org/repo1.git",
+			"url": "https://github.com/org/repo1",
+			"isArchived": false,
+			"defaultBranchRef": {"name": "main"},
+			"primaryLanguage": {"name": "Go"},
+			"repositoryTopics": {"nodes": [{"topic": {"name": "cli"}}]}
+		}]
+	}}}}`, `{"data": {"organization": {"repositories": {
+		"pageInfo": {"hasNextPage": false, "endCursor": "cursor2"},
+		"nodes": [{
+			"name": "empty",
+			"nameWithOwner": "org/empty",
+			"sshUrl": "git@github.com:org/empty.git",
+			"isArchived": true,
+			"defaultBranchRef": null,
+			"primaryLanguage": null,
+			"repositoryTopics": {"nodes": []}
+		}]
+	}}}}`}}
+
+	repos, err := GetReposListGraphql(poster, "org")
+	assert.Nil(t, err)
+	assert.Len(t, repos, 2)
+	assert.Equal(t, Repo{
+		Name: "repo1",
+		FullName: "org/repo1",
+		GitUrl: "git@github.com:org/repo1.git",
+		HttpsUrl: "https://github.com/org/repo1.git",
+		PullsUrl: "https://api.github.com/repos/org/repo1/pulls",
+		ApiUrl: "https://api.github.com/repos/org/repo1",
+		DefaultBranch: "main",
+		Topics: []string{"cli"},
+		Language: "Go",
+	}, repos[0])
+	assert.True(t, repos[1].Archived)
+	assert.Equal(t, "", repos[1].DefaultBranch)
+	assert.Contains(t, poster.bodies[0], `"cursor":null`)
+	assert.Contains(t, poster.bodies[1], `"cursor":"cursor1"`)
+}
+
+func TestGetReposListGraphqlError(t *testing.T) {
+	poster := &TestHttpPosterSequence{responses: []string{`{"errors": [{"message": "Could not resolve to an Organization"}]}`}}
+	repos, err := GetReposListGraphql(poster, "org")
+	assert.Nil(t, repos)
+	assert.Contains(t, err.Error(), "Could not resolve")
+}
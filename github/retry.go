@@ -0,0 +1,225 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit is the API quota as last reported by Github
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// RetryTransport retries the requests failing on network errors and 5xx responses with an exponential backoff,
+// and waits for the rate limits to reset when Github asks to. Non idempotent requests (POST, PATCH) aren't retried on
+// network errors and 5xx responses, Github may have created the pull request or comment before failing.
+// Content-creating requests (pull requests, labels, comments...) are spaced by WriteInterval, as recommended by Github
+// to avoid secondary rate limits.
+type RetryTransport struct {
+	Transport     http.RoundTripper
+	MaxRetries    int
+	BaseDelay     time.Duration
+	MaxDelay      time.Duration
+	WriteInterval time.Duration
+
+	mutex     sync.Mutex
+	lastWrite time.Time
+	rateLimit RateLimit
+
+	// replaced in tests
+	sleep     func(time.Duration)
+	now       func() time.Time
+}
+
+func NewRetryTransport(writeInterval time.Duration) *RetryTransport {
+	return &RetryTransport{
+		Transport: http.DefaultTransport,
+		MaxRetries: 5,
+		BaseDelay: time.Second,
+		MaxDelay: time.Hour,
+		WriteInterval: writeInterval,
+	}
+}
+
+// sleepFor waits for the delay, or until the context is done
+func (R *RetryTransport) sleepFor(ctx context.Context, delay time.Duration) error {
+	if R.sleep != nil {
+		R.sleep(delay)
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (R *RetryTransport) currentTime() time.Time {
+	if R.now != nil {
+		return R.now()
+	}
+	return time.Now()
+}
+
+// RateLimit returns the quota reported by the last response, zero if none was reported yet
+func (R *RetryTransport) RateLimit() RateLimit {
+	R.mutex.Lock()
+	defer R.mutex.Unlock()
+	return R.rateLimit
+}
+
+func (R *RetryTransport) recordRateLimit(resp *http.Response) {
+	limit, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return
+	}
+	remaining, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+
+	R.mutex.Lock()
+	defer R.mutex.Unlock()
+	R.rateLimit = RateLimit{limit, remaining, time.Unix(reset, 0)}
+}
+
+// paceWrite waits until WriteInterval elapsed since the previous content-creating request. The slot of the request
+// is reserved before waiting, so that the other requests neither wait for the lock nor share the slot.
+func (R *RetryTransport) paceWrite(req *http.Request) error {
+	if req.Method == "GET" || req.Method == "HEAD" || R.WriteInterval <= 0 {
+		return nil
+	}
+	R.mutex.Lock()
+	now := R.currentTime()
+	wait := R.lastWrite.Add(R.WriteInterval).Sub(now)
+	if wait > 0 {
+		now = now.Add(wait)
+	}
+	R.lastWrite = now
+	R.mutex.Unlock()
+
+	if wait > 0 {
+		return R.sleepFor(req.Context(), wait)
+	}
+	return nil
+}
+
+// SECONDARY_RATE_LIMIT_DELAY is the least Github asks to wait after hitting a secondary rate limit without a
+// Retry-After header
+const SECONDARY_RATE_LIMIT_DELAY = time.Minute
+
+// idempotent tells whether a request can be sent again after a response, without risking a duplicate
+func idempotent(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "PUT" || method == "DELETE" || method == "OPTIONS"
+}
+
+// isSecondaryRateLimit tells whether a 403 response is a secondary rate limit one, keeping its body readable
+func isSecondaryRateLimit(resp *http.Response) bool {
+	if resp.StatusCode != 403 || resp.Body == nil {
+		return false
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return strings.Contains(strings.ToLower(string(body)), "secondary rate limit")
+}
+
+func (R *RetryTransport) backoff(attempt int) time.Duration {
+	delay := R.BaseDelay << uint(attempt)
+	if delay <= 0 || delay > R.MaxDelay {
+		return R.MaxDelay
+	}
+	return delay
+}
+
+// rateLimitDelay tells how long to wait before retrying a rate limited request, or -1 if the response isn't a
+// rate limit one
+func (R *RetryTransport) rateLimitDelay(resp *http.Response) time.Duration {
+	if resp.StatusCode != 403 && resp.StatusCode != 429 {
+		return -1
+	}
+	if retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(retryAfter) * time.Second
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err == nil {
+			delay := time.Unix(reset, 0).Sub(R.currentTime()) + time.Second
+			if delay < 0 {
+				return 0
+			}
+			return delay
+		}
+	}
+	if isSecondaryRateLimit(resp) {
+		return SECONDARY_RATE_LIMIT_DELAY
+	}
+	if resp.StatusCode == 429 {
+		return 0
+	}
+	return -1
+}
+
+func (R *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		if err := R.paceWrite(req); err != nil {
+			return nil, err
+		}
+
+		resp, err := R.Transport.RoundTrip(req)
+
+		var delay time.Duration
+		if err != nil {
+			if !idempotent(req.Method) {
+				// the request may have been sent before the connection failed
+				return nil, err
+			}
+			delay = R.backoff(attempt)
+			log.Println("Github API call failed, ", err.Error())
+		} else {
+			R.recordRateLimit(resp)
+			if delay = R.rateLimitDelay(resp); delay >= 0 {
+				if backoff := R.backoff(attempt); delay < backoff {
+					delay = backoff
+				}
+				log.Println("Github API rate limit hit, waiting ", delay)
+			} else if resp.StatusCode >= 500 && idempotent(req.Method) {
+				delay = R.backoff(attempt)
+				log.Println("Github API returned ", resp.StatusCode, ", retrying in ", delay)
+			} else {
+				return resp, nil
+			}
+		}
+
+		if attempt >= R.MaxRetries {
+			return resp, err
+		}
+		if resp != nil {
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		if delay > R.MaxDelay {
+			delay = R.MaxDelay
+		}
+		if err := R.sleepFor(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
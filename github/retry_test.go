@@ -0,0 +1,210 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+type scriptedResponse struct {
+	status  int
+	headers map[string]string
+	body    string
+	err     error
+}
+
+// TestRoundTripper plays the scripted responses in order and records the bodies it received
+type TestRoundTripper struct {
+	script []scriptedResponse
+	bodies []string
+}
+
+func (T *TestRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := ""
+	if req.Body != nil {
+		bytes, _ := ioutil.ReadAll(req.Body)
+		body = string(bytes)
+	}
+	T.bodies = append(T.bodies, body)
+	next := T.script[0]
+	T.script = T.script[1:]
+	if next.err != nil {
+		return nil, next.err
+	}
+	response := &http.Response{StatusCode: next.status, Header: http.Header{}}
+	for name, value := range next.headers {
+		response.Header.Set(name, value)
+	}
+	response.Body = &ClosingBuffer{bytes.NewBufferString(next.body)}
+	return response, nil
+}
+
+func testRetryTransport(script []scriptedResponse) (*RetryTransport, *TestRoundTripper, *[]time.Duration) {
+	roundTripper := &TestRoundTripper{script: script}
+	sleeps := &[]time.Duration{}
+	now := time.Unix(1000, 0)
+	transport := &RetryTransport{
+		Transport: roundTripper,
+		MaxRetries: 3,
+		BaseDelay: time.Second,
+		MaxDelay: time.Minute,
+		sleep: func(delay time.Duration) {
+			*sleeps = append(*sleeps, delay)
+		},
+		now: func() time.Time {
+			return now
+		},
+	}
+	return transport, roundTripper, sleeps
+}
+
+func TestRetryTransportServerErrors(t *testing.T) {
+	transport, roundTripper, sleeps := testRetryTransport([]scriptedResponse{
+		{status: 502},
+		{err: errors.New("connection reset")},
+		{status: 201},
+	})
+	req, _ := http.NewRequest("PUT", "https://api.github.com/repos/org/repo1/pulls/1/merge", bytes.NewBufferString("{}"))
+	resp, err := transport.RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *sleeps)
+	assert.Equal(t, []string{"{}", "{}", "{}"}, roundTripper.bodies, "the body must be sent again on retries")
+}
+
+func TestRetryTransportNonIdempotent(t *testing.T) {
+	transport, roundTripper, sleeps := testRetryTransport([]scriptedResponse{
+		{err: errors.New("connection reset")},
+		{status: 502},
+	})
+	req, _ := http.NewRequest("POST", "https://api.github.com/repos/org/repo1/pulls", bytes.NewBufferString("{}"))
+	_, err := transport.RoundTrip(req)
+	assert.Equal(t, "connection reset", err.Error(), "the pull request may have been created")
+
+	req, _ = http.NewRequest("PATCH", "https://api.github.com/repos/org/repo1/pulls/1", bytes.NewBufferString("{}"))
+	resp, err := transport.RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 502, resp.StatusCode, "the pull request may have been updated")
+	assert.Empty(t, *sleeps)
+	assert.Len(t, roundTripper.bodies, 2)
+}
+
+func TestRetryTransportSecondaryRateLimit(t *testing.T) {
+	transport, _, sleeps := testRetryTransport([]scriptedResponse{
+		{status: 403, body: `{"message": "You have exceeded a secondary rate limit. Please wait a few minutes."}`},
+		{status: 201},
+	})
+	req, _ := http.NewRequest("POST", "https://api.github.com/repos/org/repo1/pulls", bytes.NewBufferString("{}"))
+	resp, _ := transport.RoundTrip(req)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, []time.Duration{SECONDARY_RATE_LIMIT_DELAY}, *sleeps)
+}
+
+func TestRetryTransportGivesUp(t *testing.T) {
+	transport, _, sleeps := testRetryTransport([]scriptedResponse{
+		{status: 500}, {status: 500}, {status: 500}, {status: 503},
+	})
+	req, _ := http.NewRequest("GET", "https://api.github.com/orgs/org/repos", nil)
+	resp, err := transport.RoundTrip(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 503, resp.StatusCode)
+	assert.Len(t, *sleeps, 3)
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	transport, _, sleeps := testRetryTransport([]scriptedResponse{
+		{status: 403, headers: map[string]string{"Retry-After": "30"}},
+		{status: 200},
+	})
+	req, _ := http.NewRequest("GET", "https://api.github.com/orgs/org/repos", nil)
+	resp, _ := transport.RoundTrip(req)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, []time.Duration{30 * time.Second}, *sleeps)
+}
+
+func TestRetryTransportRateLimitReset(t *testing.T) {
+	transport, _, sleeps := testRetryTransport([]scriptedResponse{
+		{status: 403, headers: map[string]string{
+			"X-RateLimit-Limit": "5000",
+			"X-RateLimit-Remaining": "0",
+			"X-RateLimit-Reset": "1020",
+		}},
+		{status: 200, headers: map[string]string{
+			"X-RateLimit-Limit": "5000",
+			"X-RateLimit-Remaining": "4999",
+			"X-RateLimit-Reset": "4600",
+		}},
+	})
+	req, _ := http.NewRequest("GET", "https://api.github.com/orgs/org/repos", nil)
+	resp, _ := transport.RoundTrip(req)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, []time.Duration{21 * time.Second}, *sleeps)
+	assert.Equal(t, RateLimit{5000, 4999, time.Unix(4600, 0)}, transport.RateLimit())
+}
+
+func TestRetryTransportForbidden(t *testing.T) {
+	transport, _, sleeps := testRetryTransport([]scriptedResponse{
+		{status: 403, headers: map[string]string{"X-RateLimit-Remaining": "4000"}, body: `{"message": "Forbidden"}`},
+	})
+	req, _ := http.NewRequest("GET", "https://api.github.com/orgs/org/repos", nil)
+	resp, _ := transport.RoundTrip(req)
+	assert.Equal(t, 403, resp.StatusCode)
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, `{"message": "Forbidden"}`, string(body))
+	assert.Empty(t, *sleeps)
+}
+
+func TestRetryTransportPacesWrites(t *testing.T) {
+	script := []scriptedResponse{}
+	for i := 0; i < 6; i++ {
+		script = append(script, scriptedResponse{status: 200})
+	}
+	transport, _, sleeps := testRetryTransport(script)
+	transport.WriteInterval = 2 * time.Second
+	transport.sleep = func(delay time.Duration) {
+		// the lock is released while waiting
+		transport.RateLimit()
+		*sleeps = append(*sleeps, delay)
+	}
+
+	for i := 0; i < 3; i++ {
+		get, _ := http.NewRequest("GET", "https://api.github.com/repos/org/repo" + strconv.Itoa(i), nil)
+		transport.RoundTrip(get)
+		post, _ := http.NewRequest("POST", "https://api.github.com/repos/org/repo" + strconv.Itoa(i) + "/pulls", nil)
+		transport.RoundTrip(post)
+	}
+	assert.Equal(t, []time.Duration{2 * time.Second, 4 * time.Second}, *sleeps, "each write gets its own slot")
+}
+
+func TestRetryTransportCancelled(t *testing.T) {
+	transport, roundTripper, _ := testRetryTransport([]scriptedResponse{
+		{status: 502},
+		{status: 200},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	transport.sleep = func(delay time.Duration) {
+		cancel()
+	}
+	req, _ := http.NewRequest("GET", "https://api.github.com/orgs/org/repos", nil)
+	resp, err := transport.RoundTrip(req.WithContext(ctx))
+	assert.Nil(t, resp)
+	assert.Equal(t, context.Canceled, err)
+	assert.Len(t, roundTripper.bodies, 1, "no retry once the context is done")
+}
+
+func TestAuthHttpInterfaceWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client := WithContext(&AuthHttpInterface{Username: "user", Password: "token"}, ctx)
+	_, err := client.Get("https://api.github.com/orgs/org/repos")
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	mock := &TestHttpClient{}
+	assert.Equal(t, mock, WithContext(mock, ctx), "clients not supporting contexts are kept as is")
+}
@@ -0,0 +1,129 @@
+package gitignore
+
+import (
+	"github.com/transcovo/foreachrepo/vfs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Compile translates a gitignore-style pattern into a regexp matching slash separated paths.
+// A pattern matching a directory also matches everything below it, except the patterns ending with a wildcard
+// segment: docs/* only matches the direct children of docs, as in CODEOWNERS.
+func Compile(pattern string) (*regexp.Regexp, error) {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	lastSegment := pattern[strings.LastIndex(pattern, "/") + 1:]
+	wildcardSegment := strings.Contains(lastSegment, "*") && lastSegment != "**"
+
+	expression := "^"
+	if !anchored {
+		expression += "(?:.*/)?"
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expression += "(?:.*/)?"
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expression += ".*"
+			i += 1
+		case pattern[i] == '*':
+			expression += "[^/]*"
+		case pattern[i] == '?':
+			expression += "[^/]"
+		default:
+			expression += regexp.QuoteMeta(pattern[i:i + 1])
+		}
+	}
+	if dirOnly {
+		expression += "/.*$"
+	} else if wildcardSegment {
+		expression += "$"
+	} else {
+		expression += "(?:/.*)?$"
+	}
+	return regexp.Compile(expression)
+}
+
+type rule struct {
+	// the directory of the .gitignore file, the pattern applying to the paths below it
+	base    string
+	negated bool
+	dirOnly bool
+	regexp  *regexp.Regexp
+}
+
+// Matcher tells which paths the .gitignore files of a repo exclude
+type Matcher struct {
+	rules []rule
+}
+
+// Parse adds the patterns of a .gitignore file found in the base directory, "" for the root of the repo
+func (M *Matcher) Parse(base string, content string) {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, " \r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		negated := strings.HasPrefix(line, "!")
+		line = strings.TrimPrefix(line, "!")
+		compiled, err := Compile(line)
+		if err != nil {
+			continue
+		}
+		M.rules = append(M.rules, rule{base, negated, strings.HasSuffix(line, "/"), compiled})
+	}
+}
+
+// Load reads the .gitignore files among the names of the files of the repo
+func Load(files vfs.FS, names []string) (*Matcher, error) {
+	matcher := &Matcher{}
+	for _, name := range names {
+		if path.Base(name) != ".gitignore" {
+			continue
+		}
+		content, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		base := path.Dir(name)
+		if base == "." {
+			base = ""
+		}
+		matcher.Parse(base, string(content))
+	}
+	return matcher, nil
+}
+
+// Ignored tells whether a path is excluded, the last matching pattern taking precedence as with git. The files of
+// an excluded directory are excluded too, whatever the patterns matching them.
+func (M *Matcher) Ignored(name string) bool {
+	segments := strings.Split(name, "/")
+	for i := 1; i < len(segments); i++ {
+		if M.matches(strings.Join(segments[:i], "/"), true) {
+			return true
+		}
+	}
+	return M.matches(name, false)
+}
+
+func (M *Matcher) matches(name string, dir bool) bool {
+	ignored := false
+	for _, rule := range M.rules {
+		relative := name
+		if rule.base != "" {
+			if !strings.HasPrefix(name, rule.base + "/") {
+				continue
+			}
+			relative = strings.TrimPrefix(name, rule.base + "/")
+		}
+		// the directory only patterns match the directories with their trailing slash
+		if rule.regexp.MatchString(relative) || dir && rule.dirOnly && rule.regexp.MatchString(relative + "/") {
+			ignored = !rule.negated
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,54 @@
+package gitignore
+
+import (
+	"github.com/transcovo/foreachrepo/vfs"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	compiled, err := Compile("**/*.js")
+	assert.Nil(t, err)
+	assert.True(t, compiled.MatchString("index.js"))
+	assert.True(t, compiled.MatchString("lib/deep/index.js"))
+	assert.False(t, compiled.MatchString("index.json"))
+
+	compiled, err = Compile("/build/")
+	assert.Nil(t, err)
+	assert.True(t, compiled.MatchString("build/a.log"))
+	assert.False(t, compiled.MatchString("src/build/a.log"))
+	assert.False(t, compiled.MatchString("build"))
+
+	compiled, err = Compile("docs/*")
+	assert.Nil(t, err)
+	assert.True(t, compiled.MatchString("docs/index.md"))
+	assert.False(t, compiled.MatchString("nested/docs/index.md"))
+	assert.False(t, compiled.MatchString("docs/a/b.md"))
+
+	compiled, err = Compile("docs/")
+	assert.Nil(t, err)
+	assert.True(t, compiled.MatchString("docs/a/b.md"))
+}
+
+func TestIgnored(t *testing.T) {
+	files := vfs.NewMemory(map[string][]byte{
+		".gitignore": []byte("# build output\ndist/\n*.log\n!keep.log\ntmp/*\n!tmp/keep/\n"),
+		"lib/.gitignore": []byte("generated.js\n"),
+		"index.js": []byte(""),
+	})
+	names, _ := files.List()
+	matcher, err := Load(files, names)
+	assert.Nil(t, err)
+
+	assert.True(t, matcher.Ignored("dist/index.js"))
+	assert.True(t, matcher.Ignored("lib/dist/index.js"))
+	assert.True(t, matcher.Ignored("debug.log"))
+	assert.False(t, matcher.Ignored("keep.log"))
+	assert.True(t, matcher.Ignored("lib/generated.js"))
+	assert.True(t, matcher.Ignored("lib/sub/generated.js"))
+	assert.False(t, matcher.Ignored("generated.js"))
+	assert.False(t, matcher.Ignored("index.js"))
+	// the files below an ignored directory stay ignored
+	assert.True(t, matcher.Ignored("tmp/cache/a/b.json"))
+	assert.False(t, matcher.Ignored("tmp/keep/a.json"))
+}
@@ -0,0 +1,168 @@
+package gitlab
+
+import (
+	"errors"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/httpapi"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const PROVIDER = "gitlab"
+
+// Client calls the v4 API of a GitLab instance, authenticated with a personal access token
+type Client struct {
+	httpapi.Client
+}
+
+func NewClient(baseUrl string, token string) *Client {
+	return &Client{httpapi.Client{Provider: "GitLab", BaseUrl: baseUrl, AuthHeader: "PRIVATE-TOKEN", AuthValue: token}}
+}
+
+func (C *Client) apiUrl(path string) string {
+	return C.Url("api/v4/" + path)
+}
+
+type gitlabProject struct {
+	Id                  int
+	Name                string
+	Path_with_namespace string
+	Ssh_url_to_repo     string
+	Http_url_to_repo    string
+	Default_branch      string
+	Archived            bool
+	Topics              []string
+}
+
+func (C *Client) toRepo(project gitlabProject) github.Repo {
+	apiUrl := C.apiUrl("projects/" + strconv.Itoa(project.Id))
+	return github.Repo{
+		Name: project.Name,
+		FullName: C.Host() + "/" + project.Path_with_namespace,
+		GitUrl: project.Ssh_url_to_repo,
+		HttpsUrl: project.Http_url_to_repo,
+		PullsUrl: apiUrl + "/merge_requests",
+		ApiUrl: apiUrl,
+		DefaultBranch: project.Default_branch,
+		Archived: project.Archived,
+		Topics: project.Topics,
+		Provider: PROVIDER,
+	}
+}
+
+// GetGroupProjects lists the projects of a group, given by id or full path, and of its subgroups
+func (C *Client) GetGroupProjects(group string) ([]github.Repo, error) {
+	query := url.Values{}
+	query.Set("include_subgroups", "true")
+	query.Set("per_page", "100")
+	pageUrl := C.apiUrl("groups/" + url.PathEscape(group) + "/projects?" + query.Encode())
+
+	repos := []github.Repo{}
+	for i := 1; pageUrl != ""; i++ {
+		log.Print("Loading page ", i)
+
+		page := make([]gitlabProject, 0)
+		nextUrl, err := C.Call("GET", pageUrl, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, project := range page {
+			repos = append(repos, C.toRepo(project))
+		}
+		pageUrl = nextUrl
+	}
+	return repos, nil
+}
+
+type gitlabUser struct {
+	Id int
+}
+
+// userIds resolves usernames to user ids, the unknown ones being reported as warnings
+func (C *Client) userIds(usernames []string, role string) ([]int, []string) {
+	ids := []int{}
+	warnings := []string{}
+	for _, username := range usernames {
+		users := make([]gitlabUser, 0)
+		_, err := C.Call("GET", C.apiUrl("users?username=" + url.QueryEscape(username)), nil, &users)
+		if err == nil && len(users) == 0 {
+			err = errors.New("unknown user " + username)
+		}
+		if err != nil {
+			warnings = append(warnings, "Could not add " + role + " " + username + ": " + err.Error())
+			continue
+		}
+		ids = append(ids, users[0].Id)
+	}
+	return ids, warnings
+}
+
+type gitlabMilestone struct {
+	Id    int
+	Title string
+}
+
+func (C *Client) milestoneId(repo github.Repo, milestone string) (int, error) {
+	milestones := make([]gitlabMilestone, 0)
+	_, err := C.Call("GET", repo.ApiUrl + "/milestones?state=active&title=" + url.QueryEscape(milestone), nil, &milestones)
+	if err != nil {
+		return 0, err
+	}
+	if len(milestones) == 0 {
+		return 0, errors.New("Milestone " + milestone + " not found")
+	}
+	return milestones[0].Id, nil
+}
+
+type gitlabMergeRequest struct {
+	Iid     int
+	Web_url string
+}
+
+// CreateMergeRequest opens a merge request with the labels, assignees, reviewers and milestone of the options.
+// Assignees, reviewers or milestone that can't be found are left out and reported as warnings. Team reviewers
+// have no GitLab equivalent and are ignored.
+func (C *Client) CreateMergeRequest(repo github.Repo, branch string, title string, options github.PullRequestOptions) (github.PullRequest, []string) {
+	assigneeIds, warnings := C.userIds(options.Assignees, "assignee")
+	reviewerIds, reviewerWarnings := C.userIds(options.Reviewers, "reviewer")
+	warnings = append(warnings, reviewerWarnings...)
+
+	targetBranch := repo.DefaultBranch
+	if targetBranch == "" {
+		targetBranch = "master"
+	}
+	if options.Draft {
+		title = "Draft: " + title
+	}
+	input := map[string]interface{}{
+		"source_branch": branch,
+		"target_branch": targetBranch,
+		"title": title,
+		"description": "Generated by foreachrepo",
+		"labels": strings.Join(options.Labels, ","),
+		"assignee_ids": assigneeIds,
+		"reviewer_ids": reviewerIds,
+	}
+	if options.Milestone != "" {
+		milestoneId, err := C.milestoneId(repo, options.Milestone)
+		if err != nil {
+			warnings = append(warnings, "Could not set milestone: " + err.Error())
+		} else {
+			input["milestone_id"] = milestoneId
+		}
+	}
+
+	result := &gitlabMergeRequest{}
+	_, err := C.Call("POST", repo.PullsUrl, input, result)
+	if err != nil {
+		panic(err)
+	}
+	pull := github.PullRequest{
+		Number: result.Iid,
+		Url: result.Web_url,
+		ApiUrl: repo.PullsUrl + "/" + strconv.Itoa(result.Iid),
+	}
+	return pull, warnings
+}
@@ -0,0 +1,150 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/httpapi"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// gitlabStandIn serves the few GitLab v4 API endpoints used by foreachrepo, and records the merge requests created
+type gitlabStandIn struct {
+	server        *httptest.Server
+	mergeRequests []map[string]interface{}
+}
+
+func newGitlabStandIn() *gitlabStandIn {
+	standIn := &gitlabStandIn{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/groups/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+			w.WriteHeader(401)
+			fmt.Fprint(w, `{"message": "401 Unauthorized"}`)
+			return
+		}
+		if r.URL.EscapedPath() != "/api/v4/groups/acme%2Fbackend/projects" || r.URL.Query().Get("include_subgroups") != "true" {
+			w.WriteHeader(404)
+			fmt.Fprint(w, `{"message": "404 Group Not Found"}`)
+			return
+		}
+		if r.URL.Query().Get("page") == "" {
+			nextUrl := standIn.server.URL + "/api/v4/groups/acme%2Fbackend/projects?include_subgroups=true&page=2&per_page=100"
+			w.Header().Set("Link", "<" + nextUrl + `>; rel="next"`)
+			fmt.Fprint(w, `[{"id": 1, "name": "api", "path_with_namespace": "acme/backend/api",
+				"ssh_url_to_repo": "git@gitlab.example.com:acme/backend/api.git", "default_branch": "main",
+				"topics": ["go"]}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"id": 2, "name": "worker", "path_with_namespace": "acme/backend/jobs/worker",
+			"ssh_url_to_repo": "git@gitlab.example.com:acme/backend/jobs/worker.git", "archived": true}]`)
+	})
+	mux.HandleFunc("/api/v4/users", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("username") {
+		case "alice":
+			fmt.Fprint(w, `[{"id": 11, "username": "alice"}]`)
+		case "bob":
+			fmt.Fprint(w, `[{"id": 12, "username": "bob"}]`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	})
+	mux.HandleFunc("/api/v4/projects/1/milestones", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("title") == "Q4" {
+			fmt.Fprint(w, `[{"id": 5, "title": "Q4"}]`)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("/api/v4/projects/1/merge_requests", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mergeRequest := map[string]interface{}{}
+		json.Unmarshal(body, &mergeRequest)
+		standIn.mergeRequests = append(standIn.mergeRequests, mergeRequest)
+		w.WriteHeader(201)
+		fmt.Fprint(w, `{"iid": 7, "web_url": "https://gitlab.example.com/acme/backend/api/-/merge_requests/7"}`)
+	})
+	standIn.server = httptest.NewServer(mux)
+	return standIn
+}
+
+func TestGetGroupProjects(t *testing.T) {
+	standIn := newGitlabStandIn()
+	defer standIn.server.Close()
+	client := NewClient(standIn.server.URL, "secret")
+
+	repos, err := client.GetGroupProjects("acme/backend")
+	assert.Nil(t, err)
+	assert.Len(t, repos, 2)
+	assert.Equal(t, "api", repos[0].Name)
+	assert.Equal(t, client.Host() + "/acme/backend/api", repos[0].FullName)
+	assert.Equal(t, "git@gitlab.example.com:acme/backend/api.git", repos[0].GitUrl)
+	assert.Equal(t, standIn.server.URL + "/api/v4/projects/1", repos[0].ApiUrl)
+	assert.Equal(t, standIn.server.URL + "/api/v4/projects/1/merge_requests", repos[0].PullsUrl)
+	assert.Equal(t, "main", repos[0].DefaultBranch)
+	assert.Equal(t, []string{"go"}, repos[0].Topics)
+	assert.Equal(t, PROVIDER, repos[0].Provider)
+	assert.Equal(t, "worker", repos[1].Name)
+	assert.True(t, repos[1].Archived)
+}
+
+func TestGetGroupProjectsUnauthorized(t *testing.T) {
+	standIn := newGitlabStandIn()
+	defer standIn.server.Close()
+	client := NewClient(standIn.server.URL, "wrong")
+
+	repos, err := client.GetGroupProjects("acme/backend")
+	assert.Nil(t, repos)
+	assert.Equal(t, 401, err.(*httpapi.ApiError).StatusCode)
+}
+
+func TestCreateMergeRequest(t *testing.T) {
+	standIn := newGitlabStandIn()
+	defer standIn.server.Close()
+	client := NewClient(standIn.server.URL, "secret")
+	repo := github.Repo{
+		Name: "api",
+		ApiUrl: standIn.server.URL + "/api/v4/projects/1",
+		PullsUrl: standIn.server.URL + "/api/v4/projects/1/merge_requests",
+		DefaultBranch: "main",
+	}
+	options := github.PullRequestOptions{
+		Labels: []string{"tech", "deps"},
+		Assignees: []string{"alice", "nobody"},
+		Reviewers: []string{"bob"},
+		Milestone: "Q4",
+		Draft: true,
+	}
+
+	pull, warnings := client.CreateMergeRequest(repo, "freeze-all-deps", "TECH Freeze all dependencies", options)
+	assert.Equal(t, 7, pull.Number)
+	assert.Equal(t, "https://gitlab.example.com/acme/backend/api/-/merge_requests/7", pull.Url)
+	assert.Equal(t, standIn.server.URL + "/api/v4/projects/1/merge_requests/7", pull.ApiUrl)
+	assert.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "nobody")
+
+	assert.Len(t, standIn.mergeRequests, 1)
+	mergeRequest := standIn.mergeRequests[0]
+	assert.Equal(t, "freeze-all-deps", mergeRequest["source_branch"])
+	assert.Equal(t, "main", mergeRequest["target_branch"])
+	assert.Equal(t, "Draft: TECH Freeze all dependencies", mergeRequest["title"])
+	assert.Equal(t, "tech,deps", mergeRequest["labels"])
+	assert.Equal(t, []interface{}{11.0}, mergeRequest["assignee_ids"])
+	assert.Equal(t, []interface{}{12.0}, mergeRequest["reviewer_ids"])
+	assert.Equal(t, 5.0, mergeRequest["milestone_id"])
+}
+
+func TestCreateMergeRequestFailure(t *testing.T) {
+	standIn := newGitlabStandIn()
+	defer standIn.server.Close()
+	client := NewClient(standIn.server.URL, "secret")
+	repo := github.Repo{Name: "unknown", PullsUrl: standIn.server.URL + "/api/v4/projects/404/merge_requests"}
+
+	assert.Panics(t, func() {
+		client.CreateMergeRequest(repo, "freeze-all-deps", "TECH Freeze all dependencies", github.PullRequestOptions{})
+	})
+}
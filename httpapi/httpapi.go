@@ -0,0 +1,114 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The JSON REST APIs of the providers other than Github share this client, each provider package only mapping
+// its own endpoints and payloads.
+
+type ApiError struct {
+	Provider   string
+	StatusCode int
+	Detail     string
+}
+
+func (A *ApiError) Error() string {
+	return A.Provider + " API error " + strconv.Itoa(A.StatusCode) + " (" + A.Detail + ")"
+}
+
+// Client calls the API of a provider instance, sending AuthValue in the AuthHeader of every request
+type Client struct {
+	// Provider names the API in the errors, such as GitLab
+	Provider   string
+	BaseUrl    string
+	AuthHeader string
+	AuthValue  string
+	// HttpClient defaults to http.DefaultClient
+	HttpClient *http.Client
+	// Context defaults to context.Background()
+	Context    context.Context
+}
+
+// WithContext returns a copy of the client whose requests are cancelled when the context is done
+func (C *Client) WithContext(ctx context.Context) *Client {
+	copy := *C
+	copy.Context = ctx
+	return &copy
+}
+
+// Host is the host of the instance, prefixing the full names of its repos
+func (C *Client) Host() string {
+	parsed, err := url.Parse(C.BaseUrl)
+	if err != nil {
+		return C.BaseUrl
+	}
+	return parsed.Host
+}
+
+// Url joins a path to the base url of the instance
+func (C *Client) Url(path string) string {
+	return strings.TrimSuffix(C.BaseUrl, "/") + "/" + path
+}
+
+// Do sends a request with the authentication, http client and context of the client
+func (C *Client) Do(req *http.Request) (*http.Response, error) {
+	if C.Context != nil {
+		req = req.WithContext(C.Context)
+	}
+	if C.AuthHeader != "" {
+		req.Header.Set(C.AuthHeader, C.AuthValue)
+	}
+	if C.HttpClient != nil {
+		return C.HttpClient.Do(req)
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// Call sends input as JSON, decodes the response in output and returns the url of the next page, if any
+func (C *Client) Call(method string, url string, input interface{}, output interface{}) (string, error) {
+	var body []byte
+	if input != nil {
+		var err error
+		body, err = json.Marshal(input)
+		if err != nil {
+			return "", err
+		}
+	}
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := C.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := ioutil.ReadAll(resp.Body)
+		return "", &ApiError{C.Provider, resp.StatusCode, string(detail)}
+	}
+	return NextPageUrl(resp.Header.Get("Link")), json.NewDecoder(resp.Body).Decode(output)
+}
+
+var linkPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="([^"]+)"`)
+
+// NextPageUrl extracts the url of the next page from a Link header, empty on the last page
+func NextPageUrl(link string) string {
+	for _, match := range linkPattern.FindAllStringSubmatch(link, -1) {
+		if match[2] == "next" {
+			return match[1]
+		}
+	}
+	return ""
+}
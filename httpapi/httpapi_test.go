@@ -0,0 +1,45 @@
+package httpapi
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNextPageUrl(t *testing.T) {
+	assert.Equal(t, "https://api.github.com/x?page=3", NextPageUrl(
+		`<https://api.github.com/x?page=1>; rel="prev", <https://api.github.com/x?page=3>; rel="next"`))
+	assert.Equal(t, "", NextPageUrl(`<https://api.github.com/x?page=1>; rel="first"`))
+	assert.Equal(t, "", NextPageUrl(""))
+}
+
+func TestCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+			w.WriteHeader(401)
+			fmt.Fprint(w, `{"message": "401 Unauthorized"}`)
+			return
+		}
+		w.Header().Set("Link", `<http://example.com/items?page=2>; rel="next"`)
+		fmt.Fprint(w, `[{"id": 1}]`)
+	}))
+	defer server.Close()
+	client := &Client{Provider: "GitLab", BaseUrl: server.URL, AuthHeader: "PRIVATE-TOKEN", AuthValue: "secret"}
+
+	items := []map[string]int{}
+	nextUrl, err := client.Call("GET", client.Url("items"), nil, &items)
+	assert.Nil(t, err)
+	assert.Equal(t, "http://example.com/items?page=2", nextUrl)
+	assert.Equal(t, 1, items[0]["id"])
+
+	client.AuthValue = "wrong"
+	_, err = client.Call("GET", client.Url("items"), nil, &items)
+	assert.Equal(t, 401, err.(*ApiError).StatusCode)
+	assert.Equal(t, `GitLab API error 401 ({"message": "401 Unauthorized"})`, err.Error())
+}
+
+func TestHost(t *testing.T) {
+	assert.Equal(t, "gitlab.example.com", (&Client{BaseUrl: "https://gitlab.example.com/"}).Host())
+}
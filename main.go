@@ -9,8 +9,28 @@ import (
 	"github.com/transcovo/foreachrepo/github"
 	"strings"
 	"github.com/transcovo/foreachrepo/tasks"
+	"github.com/transcovo/foreachrepo/sources"
+	"github.com/transcovo/foreachrepo/providers"
+	"github.com/transcovo/foreachrepo/gitlab"
+	"github.com/transcovo/foreachrepo/httpapi"
+	"github.com/transcovo/foreachrepo/bitbucket"
+	"github.com/transcovo/foreachrepo/gitea"
+	"github.com/transcovo/foreachrepo/vfs"
+	"github.com/transcovo/foreachrepo/replace"
+	"github.com/transcovo/foreachrepo/filetemplate"
+	"github.com/transcovo/foreachrepo/patch"
+	"github.com/transcovo/foreachrepo/plugins"
+	"io/ioutil"
+	"context"
+	"net/http"
+	"os/signal"
+	"sort"
+	"time"
 )
 
+// GITHUB_URL is where the repos listed from Github are cloned from over HTTPS
+const GITHUB_URL = "https://github.com"
+
 const EXAMPLES = `. Examples:
 
 Bump a single npm dependency to a specific version in all repos:
@@ -22,38 +42,199 @@ Freeze all package.json dependencies to the current exact version of the current
 
 $> foreachrepo -task FREEZE -org transcovo` +
 	` -branch freeze-all-deps -message "TECH Freeze all dependencies to the current result of npm i"
-`
+
+Label the pull requests and ask for reviews:
+
+$> foreachrepo -task FREEZE -org transcovo -branch freeze-all-deps -message "TECH Freeze all dependencies"` +
+	` -label tech -label deps -reviewer octocat -team-reviewer backend -assignee octocat -milestone Q4 -draft
+
+Replace a pattern in the files of every repo, $1 referring to its first capture group:
+
+$> foreachrepo -task REPLACE -org transcovo -files '**/*.js' -pattern "require\\('lodash/(\\w+)'\\)"` +
+	` -replacement "require('lodash.\$1')" -branch lodash-modules -message "TECH Use the lodash modules"
+
+Add a standard .editorconfig to the repos not having one yet:
+
+$> foreachrepo -task TEMPLATE -org transcovo -template editorconfig.tmpl -path .editorconfig -mode create-only` +
+	` -branch editorconfig -message "TECH Add an .editorconfig"
+
+Patch a JSON or YAML file of every repo:
+
+$> foreachrepo -task PATCH -org transcovo -path tsconfig.json -patch strict.json` +
+	` -branch strict-typescript -message "TECH Enable strict type checking"`
+
+// stringList is a flag accepting either repeated or comma separated values
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, element := range strings.Split(value, ",") {
+		if element != "" {
+			*l = append(*l, element)
+		}
+	}
+	return nil
+}
+
+// retryTransport is shared by every Github API call of the process, so that rate limits and pacing apply globally
+var retryTransport = github.NewRetryTransport(time.Second)
+
+func requiredEnv(name string) string {
+	value := os.Getenv(name)
+	if value == "" {
+		log.Fatalln("Missing environement variable " + name)
+	}
+	return value
+}
+
+func githubHttpInterface() *github.AuthHttpInterface {
+	return &github.AuthHttpInterface{
+		Username: requiredEnv("GITHUB_USERNAME"),
+		Password: requiredEnv("GITHUB_PASSWORD"),
+		Client: httpapi.Client{HttpClient: &http.Client{Transport: retryTransport}},
+	}
+}
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "status":
+			statusCommand(os.Args[2:])
+			return
+		case "abandon":
+			abandonCommand(os.Args[2:])
+			return
+		case "merge":
+			mergeCommand(os.Args[2:])
+			return
+		case "plugins":
+			pluginsCommand(os.Args[2:])
+			return
+		}
+	}
+
 	g := git.Git("")
 	if !g.IsInstalled() {
 		log.Fatal("git command not found")
 	}
 
-	githubUsername := os.Getenv("GITHUB_USERNAME")
-	if githubUsername == "" {
-		log.Fatalln("Missing environement variable GITHUB_USERNAME")
-	}
-
-	githubPassword := os.Getenv("GITHUB_PASSWORD")
-	if githubPassword == "" {
-		log.Fatalln("Missing environement variable GITHUB_PASSWORD")
-	}
 
 	// generic, mandatory
 	taskName := flag.String("task", "DEFAULT", "The task to execute")
-	organization := flag.String("org", "DEFAULT", "The organization to scan")
+	var organizations, users, teams, searches stringList
+	flag.Var(&organizations, "org", "An organization to scan (repeatable)")
+	flag.Var(&users, "user", "A user whose repos to scan (repeatable)")
+	flag.Var(&teams, "team", "An organization team, as org/team-slug, whose repos to scan (repeatable)")
+	flag.Var(&searches, "search", "A code search query, the repos with matching files are scanned (repeatable)")
+	mine := flag.Bool("mine", false, "Scan the repos the authenticated user has access to")
+	var gitlabGroups stringList
+	flag.Var(&gitlabGroups, "gitlab-group", "A GitLab group, by id or full path, whose projects and subgroups' projects to scan (repeatable)")
+	gitlabUrl := flag.String("gitlab-url", "https://gitlab.com", "The url of the GitLab instance, its token being read from GITLAB_TOKEN")
+	var bitbucketProjects, giteaOrganizations stringList
+	flag.Var(&bitbucketProjects, "bitbucket-project", "A Bitbucket Server project key whose repos to scan (repeatable)")
+	bitbucketUrl := flag.String("bitbucket-url", "DEFAULT", "The url of the Bitbucket Server instance, its token being read from BITBUCKET_TOKEN")
+	flag.Var(&giteaOrganizations, "gitea-org", "A Gitea organization whose repos to scan (repeatable)")
+	giteaUrl := flag.String("gitea-url", "DEFAULT", "The url of the Gitea instance, its token being read from GITEA_TOKEN")
+	var dirs, urlsFiles stringList
+	flag.Var(&dirs, "dir", "A directory of existing clones to work on in place, without opening pull requests (repeatable)")
+	flag.Var(&urlsFiles, "urls", "A file listing git urls, one per line, to work on without opening pull requests (repeatable)")
 	branchName := flag.String("branch", "DEFAULT", "The branch name to use")
 	commitMessage := flag.String("message", "DEFAULT", "The commit message to use")
+	reportFile := flag.String("report", "", "A file to save the run report to, to follow its pull requests with the status command")
+	stateFile := flag.String("state", "", "A file recording the outcome of every repo as soon as it is known")
+	useGraphql := flag.Bool("graphql", false, "List the repos with the GraphQL API, in fewer calls")
+	writeInterval := flag.Duration("write-interval", time.Second,
+		"The minimum delay between two pull request creations, or other content-creating Github API calls")
+	repoTimeout := flag.Duration("repo-timeout", 0, "Abort a repo whose commands and calls take longer, 0 for no limit")
+	resume := flag.Bool("resume", false, "Skip the repos already done or skipped according to the state file, retry the failed ones")
 
 	// for bumping single dependency parameter
 	npmDep := flag.String("npm-dep", "DEFAULT", "The npm dependency to update")
 	npmDepVersion := flag.String("npm-dep-ver", "DEFAULT", "The new version to apply everywhere")
+	var replaceFiles stringList
+	flag.Var(&replaceFiles, "files", "A gitignore-style pattern of the files to edit with REPLACE, such as '**/*.js' (repeatable)")
+	replacePattern := flag.String("pattern", "DEFAULT", "The regexp to replace with REPLACE")
+	replacement := flag.String("replacement", "", "The replacement of REPLACE, $1 or ${name} referring to the capture groups")
+	templateFile := flag.String("template", "DEFAULT", "The Go template file TEMPLATE renders")
+	filePath := flag.String("path", "DEFAULT", "The path in each repo of the file TEMPLATE writes, or PATCH patches")
+	templateMode := flag.String("mode", filetemplate.MODE_CREATE_ONLY,
+		"How TEMPLATE writes the file: create-only, overwrite, or ensure-block to manage a section of it")
+	blockComment := flag.String("block-comment", "#", "The line comment of the file, marking the section managed by ensure-block")
+	patchFile := flag.String("patch", "DEFAULT", "The JSON or YAML file of the patch PATCH applies")
+	patchType := flag.String("patch-type", patch.MODE_JSON_PATCH,
+		"json-patch for a RFC 6902 JSON Patch, or merge-patch for a RFC 7386 JSON Merge Patch")
+	pluginsDir := flag.String("plugins-dir", defaultPluginsDir(),
+		"The directory of the plugin tasks, searched before the PATH, FOREACHREPO_PLUGINS_DIR by default")
+	params := paramMap{}
+	flag.Var(params, "param", "A name=value param of a plugin task (repeatable)")
+
+	// pull request decoration, optional
+	var labels, reviewers, teamReviewers, assignees stringList
+	flag.Var(&labels, "label", "A label to add to the pull requests (repeatable)")
+	flag.Var(&reviewers, "reviewer", "A user to request a review from (repeatable)")
+	flag.Var(&teamReviewers, "team-reviewer", "A team slug to request a review from (repeatable)")
+	flag.Var(&assignees, "assignee", "A user to assign the pull requests to (repeatable)")
+	milestone := flag.String("milestone", "", "The milestone, by number or title, to set on the pull requests")
+	draft := flag.Bool("draft", false, "Open the pull requests as drafts")
+	codeOwners := flag.Bool("codeowners", false, "Request reviews from the CODEOWNERS of the changed files")
+	fork := flag.Bool("fork", false, "Push to a fork of each repo and open the pull requests from it, for repos you can't push to")
+	cloneDepth := flag.Int("clone-depth", 0, "Clone only that many commits of each repo, the whole history by default")
+	partialClone := flag.Bool("partial-clone", false, "Clone without the file contents of the history, fetched on demand")
+	apiOnly := flag.Bool("api-only", false,
+		"Commit through the Github API instead of cloning the repos, for tasks only editing a few files such as BUMP")
+	cloneProtocol := flag.String("clone-protocol", "ssh",
+		"ssh, or https to clone and push with the API tokens instead of SSH keys")
+	cloneCache := flag.String("clone-cache", "", "A directory keeping a mirror of every repo, to only fetch what changed on later runs")
+	verify := flag.String("verify", "", "A command checking each repo after the task, such as \"npm test\", or auto to detect it")
+	pushUnverified := flag.Bool("push-unverified", false, "Push the repos failing verification, opening draft pull requests")
+	authorName := flag.String("author-name", os.Getenv("FOREACHREPO_AUTHOR_NAME"),
+		"The name the commits are made with, FOREACHREPO_AUTHOR_NAME or the git config of the host by default")
+	authorEmail := flag.String("author-email", os.Getenv("FOREACHREPO_AUTHOR_EMAIL"),
+		"The email the commits are made with, FOREACHREPO_AUTHOR_EMAIL or the git config of the host by default")
+	signOff := flag.Bool("signoff", false, "Add a Signed-off-by trailer to the commits")
+	var coAuthors stringList
+	flag.Var(&coAuthors, "co-author", "A \"Name <email>\" to credit with a Co-authored-by trailer (repeatable)")
+	sign := flag.Bool("sign", false, "Sign the commits, with -signing-key or the default key of the host")
+	signingKey := flag.String("signing-key", "", "The GPG key id, or SSH public key file, to sign the commits with")
+	signingFormat := flag.String("signing-format", "", "The signing key format: openpgp (default), ssh or x509")
+	var fallbackReviewers stringList
+	flag.Var(&fallbackReviewers, "fallback-reviewer",
+		"A user or @org/team to request a review from when the repo has no CODEOWNERS (repeatable)")
 
 	flag.Parse()
+	retryTransport.WriteInterval = *writeInterval
 
-	if *organization == "DEFAULT" {
-		log.Fatalln("organization flag required", EXAMPLES)
+	if len(organizations) == 0 && len(users) == 0 && len(teams) == 0 && len(searches) == 0 && !*mine &&
+		len(dirs) == 0 && len(urlsFiles) == 0 && len(gitlabGroups) == 0 && len(bitbucketProjects) == 0 &&
+		len(giteaOrganizations) == 0 {
+		log.Fatalln("org, user, team, search, mine, gitlab-group, bitbucket-project, gitea-org, dir or urls flag required",
+			EXAMPLES)
+	}
+	if len(bitbucketProjects) > 0 && *bitbucketUrl == "DEFAULT" {
+		log.Fatalln("bitbucket-url flag required with bitbucket-project", EXAMPLES)
+	}
+	if len(giteaOrganizations) > 0 && *giteaUrl == "DEFAULT" {
+		log.Fatalln("gitea-url flag required with gitea-org", EXAMPLES)
+	}
+	for _, team := range teams {
+		if !strings.Contains(team, "/") {
+			log.Fatalln("team flag must be given as org/team-slug, got ", team, EXAMPLES)
+		}
+	}
+	if *cloneProtocol != "ssh" && *cloneProtocol != "https" {
+		log.Fatalln("clone-protocol flag must be ssh or https, got ", *cloneProtocol, EXAMPLES)
+	}
+	if *apiOnly && (*fork || *verify != "" || *sign || *signingKey != "") {
+		log.Fatalln("api-only flag can't be used with the fork, verify or signing flags", EXAMPLES)
+	}
+	if *apiOnly && (len(dirs) > 0 || len(urlsFiles) > 0) {
+		log.Fatalln("api-only flag can't be used with the dir or urls flags, their repos have no API", EXAMPLES)
+	}
+	if *apiOnly && *signOff && (*authorName == "" || *authorEmail == "") {
+		log.Fatalln("signoff flag needs the author-name and author-email flags with the api-only flag", EXAMPLES)
 	}
 	if *taskName == "DEFAULT" {
 		log.Fatalln("task flag required", EXAMPLES)
@@ -80,25 +261,273 @@ func main() {
 		}
 	} else if *taskName == "FREEZE" {
 		task = FreezeTask{}
+	} else if *taskName == "REPLACE" {
+		if len(replaceFiles) == 0 {
+			log.Fatalln("files flag required when task is REPLACE", EXAMPLES)
+		}
+		if *replacePattern == "DEFAULT" {
+			log.Fatalln("pattern flag required when task is REPLACE", EXAMPLES)
+		}
+		replaceTask, err := replace.NewTask(replaceFiles, *replacePattern, *replacement)
+		if err != nil {
+			log.Fatalln("Invalid REPLACE flags: ", err)
+		}
+		task = replaceTask
+	} else if *taskName == "TEMPLATE" {
+		if *templateFile == "DEFAULT" {
+			log.Fatalln("template flag required when task is TEMPLATE", EXAMPLES)
+		}
+		if *filePath == "DEFAULT" {
+			log.Fatalln("path flag required when task is TEMPLATE", EXAMPLES)
+		}
+		source, err := ioutil.ReadFile(*templateFile)
+		if err != nil {
+			log.Fatalln("Could not read the template: ", err)
+		}
+		templateTask, err := filetemplate.NewTask(*filePath, string(source), *templateMode, *blockComment)
+		if err != nil {
+			log.Fatalln("Invalid TEMPLATE flags: ", err)
+		}
+		task = templateTask
+	} else if *taskName == "PATCH" {
+		if *patchFile == "DEFAULT" {
+			log.Fatalln("patch flag required when task is PATCH", EXAMPLES)
+		}
+		if *filePath == "DEFAULT" {
+			log.Fatalln("path flag required when task is PATCH", EXAMPLES)
+		}
+		source, err := ioutil.ReadFile(*patchFile)
+		if err != nil {
+			log.Fatalln("Could not read the patch: ", err)
+		}
+		patchTask, err := patch.NewTask(*filePath, *patchType, source)
+		if err != nil {
+			log.Fatalln("Invalid PATCH flags: ", err)
+		}
+		task = patchTask
+	} else if plugin := findPlugin(*pluginsDir, *taskName); plugin != nil {
+		pluginParams, err := plugin.Params(params)
+		if err != nil {
+			log.Fatalln(err.Error(), PLUGINS_EXAMPLES)
+		}
+		task = &plugins.Task{Plugin: plugin, Params: pluginParams}
 	} else {
-		log.Fatalln("Unknown task type ", *taskName, EXAMPLES)
+		log.Fatalln("Unknown task type ", *taskName, ", nor plugin task found, see foreachrepo plugins", EXAMPLES)
 	}
 
-	httpInterface := &github.AuthHttpInterface{githubUsername, githubPassword}
+	options := tasks.Options{
+		BranchName: *branchName,
+		CommitMessage: *commitMessage,
+		PullRequest: github.PullRequestOptions{
+			Labels: labels,
+			Reviewers: reviewers,
+			TeamReviewers: teamReviewers,
+			Assignees: assignees,
+			Milestone: *milestone,
+			Draft: *draft,
+		},
+		CodeOwners: *codeOwners,
+		FallbackReviewers: fallbackReviewers,
+		Fork: *fork,
+		Verify: *verify,
+		PushUnverified: *pushUnverified,
+		Commit: git.CommitOptions{
+			AuthorName: *authorName,
+			AuthorEmail: *authorEmail,
+			SignOff: *signOff,
+			CoAuthors: coAuthors,
+			Sign: *sign || *signingKey != "",
+			SigningKey: *signingKey,
+			SigningFormat: *signingFormat,
+		},
+		ApiOnly: *apiOnly,
+		Https: *cloneProtocol == "https",
+		Credentials: map[string]*git.HttpsCredentials{},
+		Clone: git.CloneOptions{
+			Depth: *cloneDepth,
+			Partial: *partialClone,
+			CacheDir: *cloneCache,
+		},
+	}
+
+	// Github credentials are only needed when some repos come from Github
+	httpInterface := &github.AuthHttpInterface{}
+	if len(organizations) > 0 || len(users) > 0 || len(teams) > 0 || len(searches) > 0 || *mine {
+		httpInterface = githubHttpInterface()
+		if options.Https {
+			options.Credentials[GITHUB_URL] = &git.HttpsCredentials{Url: GITHUB_URL, Username: httpInterface.Username, Password: httpInterface.Password}
+		}
+	}
 
-	repos, err := github.GetReposList(httpInterface, *organization)
+	repoSources := []sources.RepoSource{}
+	for _, organization := range organizations {
+		repoSources = append(repoSources, &sources.GithubOrgSource{Client: httpInterface, Organization: organization, Graphql: *useGraphql})
+	}
+	for _, user := range users {
+		repoSources = append(repoSources, &sources.GithubUserSource{Client: httpInterface, User: user})
+	}
+	for _, team := range teams {
+		slash := strings.Index(team, "/")
+		repoSources = append(repoSources, &sources.GithubTeamSource{
+			Client: httpInterface,
+			Organization: team[:slash],
+			Team: team[slash + 1:],
+		})
+	}
+	if *mine {
+		repoSources = append(repoSources, &sources.GithubAuthenticatedUserSource{Client: httpInterface})
+	}
+	for _, search := range searches {
+		repoSources = append(repoSources, &sources.GithubSearchSource{Client: httpInterface, Query: search})
+	}
+	registry := providers.Registry{"": &providers.GithubProvider{Client: httpInterface}}
+	addProvider := func(name string, provider providers.Provider, scopes []string) {
+		registry[name] = provider
+		for _, scope := range scopes {
+			repoSources = append(repoSources, &sources.ProviderSource{Provider: provider, Scope: scope})
+		}
+	}
+	if len(gitlabGroups) > 0 {
+		token := requiredEnv("GITLAB_TOKEN")
+		client := gitlab.NewClient(*gitlabUrl, token)
+		if options.Https {
+			origin := git.UrlOrigin(*gitlabUrl)
+			options.Credentials[origin] = &git.HttpsCredentials{Url: origin, Username: "oauth2", Password: token}
+		}
+		addProvider(gitlab.PROVIDER, &providers.GitlabProvider{Client: client}, gitlabGroups)
+	}
+	if len(bitbucketProjects) > 0 {
+		token := requiredEnv("BITBUCKET_TOKEN")
+		client := bitbucket.NewClient(*bitbucketUrl, token)
+		if options.Https {
+			origin := git.UrlOrigin(*bitbucketUrl)
+			options.Credentials[origin] = &git.HttpsCredentials{Url: origin, Username: requiredEnv("BITBUCKET_USERNAME"), Password: token}
+		}
+		addProvider(bitbucket.PROVIDER, &providers.BitbucketProvider{Client: client}, bitbucketProjects)
+	}
+	if len(giteaOrganizations) > 0 {
+		token := requiredEnv("GITEA_TOKEN")
+		client := gitea.NewClient(*giteaUrl, token)
+		if options.Https {
+			origin := git.UrlOrigin(*giteaUrl)
+			options.Credentials[origin] = &git.HttpsCredentials{Url: origin, Username: requiredEnv("GITEA_USERNAME"), Password: token}
+		}
+		addProvider(gitea.PROVIDER, &providers.GiteaProvider{Client: client}, giteaOrganizations)
+	}
+	for _, dir := range dirs {
+		repoSources = append(repoSources, &sources.DirSource{Dir: dir})
+	}
+	for _, urlsFile := range urlsFiles {
+		repoSources = append(repoSources, &sources.UrlListSource{File: urlsFile})
+	}
+	repos, err := sources.ListAll(repoSources)
 	if err != nil {
 		panic(err)
 	}
-	urls := []string{}
+
+	report := &tasks.Report{Campaign: *branchName, Organization: strings.Join(organizations, ",")}
+	if *resume {
+		if *stateFile == "" {
+			log.Fatalln("state flag required to resume a run", EXAMPLES)
+		}
+		previous, err := tasks.LoadReport(*stateFile)
+		if err == nil && previous.Campaign != *branchName {
+			log.Fatalln("The state file is the one of the ", previous.Campaign, " campaign, not of ", *branchName,
+				", resume with the same branch or use another state file")
+		}
+		if err == nil {
+			report = previous
+		} else if !os.IsNotExist(err) {
+			log.Fatalln("Could not load the state file:", err)
+		}
+	}
+
+	// a first interruption lets the repo in progress finish, a second one aborts it, a third one kills the process
+	runCtx, abort := context.WithCancel(context.Background())
+	defer abort()
+	stop := make(chan struct{})
+	interruptions := make(chan os.Signal, 2)
+	signal.Notify(interruptions, os.Interrupt)
+	go func() {
+		<-interruptions
+		log.Println("Interrupted, finishing the repo in progress. Interrupt again to abort it")
+		close(stop)
+		<-interruptions
+		log.Println("Interrupted again, aborting the repo in progress. Interrupt again to exit at once")
+		abort()
+		// the next interruption gets its default behavior back
+		signal.Stop(interruptions)
+	}()
+
 	for _, repo := range repos {
-		url := tasks.ExecuteTask(httpInterface, repo, task, *branchName, *commitMessage)
-		if url != "" {
-			urls = append(urls, url)
+		interrupted := false
+		select {
+		case <-stop:
+			interrupted = true
+		default:
+		}
+		if interrupted {
+			log.Println("Interrupted, the remaining repos are left for a resumed run")
+			break
+		}
+		if *resume && report.IsFinished(repo.DisplayName()) {
+			log.Println(repo.DisplayName(), " -> already processed, skip")
+			continue
+		}
+		var repoCtx context.Context
+		var cancel context.CancelFunc
+		if *repoTimeout > 0 {
+			repoCtx, cancel = context.WithTimeout(runCtx, *repoTimeout)
+		} else {
+			repoCtx, cancel = context.WithCancel(runCtx)
+		}
+		result := tasks.ExecuteTask(repoCtx, registry, repo, task, options)
+		cancel()
+		report.Record(result)
+		report.RateLimit = retryTransport.RateLimit()
+		if *stateFile != "" {
+			if err := report.Save(*stateFile); err != nil {
+				log.Fatalln("Could not save the state file:", err)
+			}
+		}
+	}
+
+	urls := []string{}
+	warnings := []string{}
+	for _, result := range report.Results {
+		if result.Url != "" {
+			urls = append(urls, result.Url)
+		}
+		for _, warning := range result.Warnings {
+			warnings = append(warnings, result.Repo + ": " + warning)
 		}
 	}
 	println("===== Done =====")
 	println(strings.Join(urls, "\n"))
+	if len(warnings) > 0 {
+		println("===== Warnings =====")
+		println(strings.Join(warnings, "\n"))
+	}
+	failures := report.FailuresByKind()
+	if len(failures) > 0 {
+		println("===== Failures =====")
+		kinds := []string{}
+		for kind := range failures {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			println(kind + ":", strings.Join(failures[kind], ", "))
+		}
+	}
+	rateLimit := retryTransport.RateLimit()
+	report.RateLimit = rateLimit
+	println("Github API quota:", rateLimit.Remaining, "/", rateLimit.Limit, "remaining, reset at", rateLimit.Reset.Format(time.RFC3339))
+	if *reportFile != "" {
+		if err := report.Save(*reportFile); err != nil {
+			log.Fatalln("Could not save the report:", err)
+		}
+	}
 }
 
 type BumpNpmDependencyTask struct {
@@ -106,12 +535,16 @@ type BumpNpmDependencyTask struct {
 	npmDepVersion string
 }
 
-func (t BumpNpmDependencyTask) Execute(dir string) error {
-	return npm.UpdatePackage(dir, t.npmDep, t.npmDepVersion)
+func (t BumpNpmDependencyTask) Files() []string {
+	return []string{"package.json"}
+}
+
+func (t BumpNpmDependencyTask) Execute(ctx context.Context, files vfs.FS) error {
+	return npm.UpdatePackage(files, t.npmDep, t.npmDepVersion)
 }
 
 type FreezeTask struct{}
 
-func (t FreezeTask) Execute(dir string) error {
-	return npm.FreezePackage(dir)
+func (t FreezeTask) Execute(ctx context.Context, files vfs.FS) error {
+	return npm.FreezePackage(ctx, files)
 }
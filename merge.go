@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"github.com/transcovo/foreachrepo/github"
+	"log"
+	"strings"
+)
+
+const MERGE_EXAMPLES = `. Examples:
+
+Squash the campaign pull requests whose required checks passed, enable auto-merge on the others, 20 at most:
+
+$> foreachrepo merge -org transcovo -campaign fixed-chpr-metrics-version -method squash -max 20`
+
+func mergeCommand(args []string) {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	organization := flags.String("org", "DEFAULT", "The organization to search the campaign pull requests in")
+	campaign := flags.String("campaign", "DEFAULT", "The campaign branch name")
+	reportFile := flags.String("report", "", "A saved run report listing the campaign pull requests, instead of searching them")
+	method := flags.String("method", "merge", "The merge method, merge, squash or rebase")
+	max := flags.Int("max", 10, "The maximum number of pull requests to merge or to enable auto-merge on")
+	flags.Parse(args)
+
+	if *campaign == "DEFAULT" && *reportFile == "" {
+		log.Fatalln("campaign or report flag required", MERGE_EXAMPLES)
+	}
+	if *method != "merge" && *method != "squash" && *method != "rebase" {
+		log.Fatalln("Unknown merge method ", *method, MERGE_EXAMPLES)
+	}
+
+	httpInterface := githubHttpInterface()
+
+	statuses, err := campaignStatuses(httpInterface, *organization, *campaign, *reportFile)
+	if err != nil {
+		log.Fatalln("Could not list the campaign pull requests:", err, MERGE_EXAMPLES)
+	}
+
+	results := []string{}
+	count := 0
+	for _, status := range statuses {
+		action, reason := github.PlanMerge(status)
+		if action != github.MERGE_SKIP && count >= *max {
+			action, reason = github.MERGE_SKIP, "max reached"
+		}
+		switch action {
+		case github.MERGE_NOW:
+			err = github.MergePullRequest(httpInterface, status, *method)
+			if err == nil {
+				reason = "merged"
+			}
+		case github.MERGE_AUTO:
+			err = github.EnableAutoMerge(httpInterface, status, *method)
+			if err == nil {
+				reason = "auto-merge enabled, " + reason
+			}
+		default:
+			err = nil
+			reason = "skipped, " + reason
+		}
+		if err != nil {
+			reason = "failed, " + err.Error()
+		} else if action != github.MERGE_SKIP {
+			count++
+		}
+		log.Println(status.Repo, " -> ", reason)
+		results = append(results, status.Repo + ": " + reason + " (" + status.Url + ")")
+	}
+	println("===== Done =====")
+	println(strings.Join(results, "\n"))
+}
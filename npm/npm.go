@@ -4,11 +4,13 @@ import (
 	"regexp"
 	"strings"
 	"io/ioutil"
-	"path/filepath"
 	"os"
 	"encoding/json"
-	"os/exec"
+	"errors"
+	"context"
+	"github.com/transcovo/foreachrepo/process"
 	"log"
+	"github.com/transcovo/foreachrepo/vfs"
 )
 
 const SPACES = "\\s*"
@@ -124,30 +126,23 @@ func UpdateDependencies(packageContent string, updates map[string]string) (strin
 	return packageContent, nil
 }
 
-// Exists reports whether the named file or directory exists.
-func exists(name string) bool {
-	if _, err := os.Stat(name); err != nil {
-		if os.IsNotExist(err) {
-			return false
-		}
-	}
-	return true
-}
-
 type NoPackageJson struct {
 	dir string
 }
 
 func (D *NoPackageJson) Error() string {
+	if D.dir == "" {
+		return "No package.json found"
+	}
 	return "No package.json found in " + D.dir
 }
 
-func UpdatePackage(dir string, dependency string, version string) error {
-	packageFile := filepath.Join(dir, "package.json")
-	if !exists(packageFile) {
-		return &NoPackageJson{dir}
+// UpdatePackage updates the dependency in the package.json of the files, which need not be on disk
+func UpdatePackage(files vfs.FS, dependency string, version string) error {
+	bytes, err := files.ReadFile("package.json")
+	if os.IsNotExist(err) {
+		return &NoPackageJson{files.Dir()}
 	}
-	bytes, err := ioutil.ReadFile(packageFile)
 	if err != nil {
 		return err
 	}
@@ -156,15 +151,11 @@ func UpdatePackage(dir string, dependency string, version string) error {
 	if err != nil {
 		return err
 	}
-	err = ioutil.WriteFile(packageFile, []byte(updatedPackageContent), 0644)
-	if err != nil {
-		return err
-	}
-	return nil
+	return files.WriteFile("package.json", []byte(updatedPackageContent), 0644)
 }
 
-func Exec(dir string, name string, elements ...string) error {
-	cmd := exec.Command(name, elements...)
+func Exec(ctx context.Context, dir string, name string, elements ...string) error {
+	cmd := process.Command(ctx, name, elements...)
 	cmd.Dir = dir
 	if err := cmd.Start(); err != nil {
 		return err
@@ -175,9 +166,9 @@ func Exec(dir string, name string, elements ...string) error {
 	return nil
 }
 
-func ExecNpmList(dir string) (map[string]string, error) {
+func ExecNpmList(ctx context.Context, dir string) (map[string]string, error) {
 	log.Print("Executing npm list")
-	cmd := exec.Command("bash", "-c", "source ~/.nvm/nvm.sh && nvm i 6 >/dev/null && npm i >/dev/null && npm list --depth 0 --json || echo")
+	cmd := process.Command(ctx, "bash", "-c", "source ~/.nvm/nvm.sh && nvm i 6 >/dev/null && npm i >/dev/null && npm list --depth 0 --json || echo")
 	cmd.Dir = dir
 
 	outPipe, err := cmd.StdoutPipe()
@@ -217,23 +208,27 @@ func ExecNpmList(dir string) (map[string]string, error) {
 	return versions, nil
 }
 
-func FreezePackage(dir string) error {
+// FreezePackage pins the dependencies of the package.json to the versions npm installs, which needs the files on disk
+func FreezePackage(ctx context.Context, files vfs.FS) error {
+	dir := files.Dir()
+	if dir == "" {
+		return errors.New("Freezing dependencies needs a clone of the repo to install them")
+	}
 	log.Print("Freezing dependencies in: ", dir)
-	packageFile := filepath.Join(dir, "package.json")
-	if !exists(packageFile) {
+	bytes, err := files.ReadFile("package.json")
+	if os.IsNotExist(err) {
 		log.Print("No package.json found, aborting")
 		return &NoPackageJson{dir}
 	}
-	bytes, err := ioutil.ReadFile(packageFile)
 	if err != nil {
 		log.Print("Could not read package.json", err.Error())
 		return err
 	}
 	packageContent := string(bytes)
 
-	Exec(dir, "rm", "-rf", "node_modules")
-	Exec(dir, "bash", "-c", "nvm i 6 && npm i")
-	versions, err := ExecNpmList(dir)
+	Exec(ctx, dir, "rm", "-rf", "node_modules")
+	Exec(ctx, dir, "bash", "-c", "nvm i 6 && npm i")
+	versions, err := ExecNpmList(ctx, dir)
 	if err != nil {
 		return err
 	}
@@ -242,10 +237,5 @@ func FreezePackage(dir string) error {
 	if err != nil {
 		return err
 	}
-	err = ioutil.WriteFile(packageFile, []byte(updatedPackageContent), 0644)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return files.WriteFile("package.json", []byte(updatedPackageContent), 0644)
 }
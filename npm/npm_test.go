@@ -1,6 +1,8 @@
 package npm
 
 import (
+	"github.com/transcovo/foreachrepo/vfs"
+	"context"
 	"github.com/stretchr/testify/assert"
 	"testing"
 	"io/ioutil"
@@ -174,7 +176,7 @@ func TestUpdatePackage(t *testing.T) {
 	if fileWriteErr != nil {
 		panic(fileWriteErr)
 	}
-	updateErr := UpdatePackage(dir, "bunyan", "1.8.2")
+	updateErr := UpdatePackage(&vfs.OS{Root: dir}, "bunyan", "1.8.2")
 	assert.Nil(t, updateErr)
 	bytes, err := ioutil.ReadFile(packageFile)
 	if err != nil {
@@ -238,7 +240,7 @@ func TestFreezePackage(t *testing.T) {
 	if fileWriteErr != nil {
 		panic(fileWriteErr)
 	}
-	updateErr := FreezePackage(dir)
+	updateErr := FreezePackage(context.Background(), &vfs.OS{Root: dir})
 	assert.Nil(t, updateErr)
 	bytes, err := ioutil.ReadFile(packageFile)
 	if err != nil {
@@ -248,3 +250,18 @@ func TestFreezePackage(t *testing.T) {
 	assert.NotContains(t, updatedPackageContent, "^")
 	assert.NotContains(t, updatedPackageContent, "~")
 }
+
+func TestUpdatePackageInMemory(t *testing.T) {
+	files := vfs.NewMemory(map[string][]byte{"package.json": []byte(SAMPLE_PACKAGE_CONTENT)})
+	assert.Nil(t, UpdatePackage(files, "bunyan", "1.8.2"))
+	assert.Equal(t, map[string][]byte{"package.json": []byte(EXPECTED_UPDATED_PACKAGE_CONTENT)}, files.Changes())
+
+	err := UpdatePackage(vfs.NewMemory(nil), "bunyan", "1.8.2")
+	assert.Equal(t, "No package.json found", err.Error())
+}
+
+func TestFreezePackageNeedsClone(t *testing.T) {
+	files := vfs.NewMemory(map[string][]byte{"package.json": []byte(SAMPLE_PACKAGE_CONTENT)})
+	err := FreezePackage(context.Background(), files)
+	assert.Equal(t, "Freezing dependencies needs a clone of the repo to install them", err.Error())
+}
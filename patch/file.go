@@ -0,0 +1,486 @@
+package patch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/transcovo/foreachrepo/tasks"
+	"github.com/transcovo/foreachrepo/vfs"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MODE_JSON_PATCH applies a RFC 6902 JSON Patch, a list of operations
+	MODE_JSON_PATCH = "json-patch"
+	// MODE_MERGE_PATCH applies a RFC 7386 JSON Merge Patch, a document merged into the file
+	MODE_MERGE_PATCH = "merge-patch"
+)
+
+// DEFAULT_INDENT is the indentation of the files too short to tell theirs
+const DEFAULT_INDENT = "  "
+
+type UnknownMode struct {
+	mode string
+}
+
+func (U *UnknownMode) Error() string {
+	return "Unknown patch type " + U.mode + ", expected " + MODE_JSON_PATCH + " or " + MODE_MERGE_PATCH
+}
+
+type NoFile struct {
+	path string
+}
+
+func (N *NoFile) Error() string {
+	return "No " + N.path + " found"
+}
+
+type UpToDate struct {
+	path string
+}
+
+func (U *UpToDate) Error() string {
+	return U.path + " is already up to date"
+}
+
+// Task patches a JSON or YAML file of every repo, YAML files being told apart by their extension
+type Task struct {
+	Path       string
+	operations []Operation
+	merge      *yaml.Node
+}
+
+func NewTask(path string, mode string, source []byte) (*Task, error) {
+	task := &Task{Path: path}
+	switch mode {
+	case MODE_JSON_PATCH:
+		operations, err := ParseOperations(source)
+		if err != nil {
+			return nil, err
+		}
+		task.operations = operations
+	case MODE_MERGE_PATCH:
+		task.merge = &yaml.Node{}
+		if err := decodeSource(source, task.merge); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, &UnknownMode{mode}
+	}
+	if task.isYaml() {
+		// the patches written in JSON would otherwise be inserted in flow style
+		for _, operation := range task.operations {
+			if operation.Value != nil {
+				clearStyle(operation.Value)
+			}
+		}
+		if task.merge != nil {
+			clearStyle(task.merge)
+		}
+	}
+	return task, nil
+}
+
+func (T *Task) Files() []string {
+	return []string{T.Path}
+}
+
+func (T *Task) isYaml() bool {
+	extension := path.Ext(T.Path)
+	return extension == ".yml" || extension == ".yaml"
+}
+
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+var indentation = regexp.MustCompile(`\n([ \t]+)\S`)
+
+// indentOf returns the indentation of the first indented line of a file
+func indentOf(content []byte) string {
+	match := indentation.FindSubmatch(content)
+	if match == nil {
+		return DEFAULT_INDENT
+	}
+	return string(match[1])
+}
+
+func (T *Task) Execute(ctx context.Context, files vfs.FS) error {
+	content, err := files.ReadFile(T.Path)
+	if os.IsNotExist(err) {
+		return &NoFile{T.Path}
+	}
+	if err != nil {
+		return err
+	}
+
+	parsed := content
+	if !T.isYaml() {
+		parsed, err = stripComments(content)
+		if err == nil {
+			parsed, err = unescapeSlashes(parsed)
+		}
+		if err != nil {
+			return &tasks.TaskFailure{Err: err}
+		}
+	}
+	documents, err := decodeAll(parsed)
+	if err != nil {
+		return &tasks.TaskFailure{Err: err}
+	}
+	var source *jsonSource
+	if len(documents) == 0 {
+		documents = []*yaml.Node{{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}}
+	} else if T.isYaml() {
+		lines := strings.Split(string(content), "\n")
+		for _, document := range documents {
+			markBlankLines(document, lines)
+		}
+	} else {
+		source, err = newJsonSource(content, documents[0])
+		if err != nil {
+			return &tasks.TaskFailure{Err: err}
+		}
+	}
+	before, err := plain(documents[0])
+	if err != nil {
+		return &tasks.TaskFailure{Err: err}
+	}
+	if T.merge != nil {
+		Merge(documents[0], T.merge)
+	} else if err := Apply(documents[0], T.operations); err != nil {
+		if _, ok := err.(*TestFailed); ok {
+			return err
+		}
+		return &tasks.TaskFailure{Err: err}
+	}
+	after, err := plain(documents[0])
+	if err != nil {
+		return &tasks.TaskFailure{Err: err}
+	}
+	if reflect.DeepEqual(before, after) {
+		return &UpToDate{T.Path}
+	}
+
+	var patched []byte
+	if T.isYaml() {
+		patched, err = encodeYaml(documents, len(indentOf(content)))
+	} else {
+		patched, err = encodeJson(documents[0], source, indentOf(content), bytes.HasSuffix(content, []byte("\n")))
+	}
+	if err != nil {
+		return &tasks.TaskFailure{Err: err}
+	}
+	return files.WriteFile(T.Path, patched, 0644)
+}
+
+// decodeAll parses the documents of a YAML file, or the single one of a JSON file
+func decodeAll(content []byte) ([]*yaml.Node, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	documents := []*yaml.Node{}
+	for {
+		document := &yaml.Node{}
+		err := decoder.Decode(document)
+		if err == io.EOF {
+			return documents, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+}
+
+// BLANK_LINE_MARKER stands for the blank lines of YAML files, which the encoder drops, until they are written
+const BLANK_LINE_MARKER = "#foreachrepo:blank-line"
+
+var blankLineMarker = regexp.MustCompile(`(?m)^[ \t]*` + BLANK_LINE_MARKER + `\n`)
+
+// markBlankLines marks the keys and items preceded by a blank line, above their comments if any, lines being the
+// lines of the file
+func markBlankLines(node *yaml.Node, lines []string) {
+	items := node.Content
+	if node.Kind == yaml.MappingNode {
+		items = []*yaml.Node{}
+		for i := 0; i < len(node.Content); i += 2 {
+			items = append(items, node.Content[i])
+		}
+	}
+	if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+		for _, item := range items {
+			first := item.Line
+			if item.HeadComment != "" {
+				first -= strings.Count(item.HeadComment, "\n") + 1
+			}
+			if first >= 2 && first - 2 < len(lines) && strings.TrimSpace(lines[first - 2]) == "" {
+				item.HeadComment = strings.TrimSuffix(BLANK_LINE_MARKER + "\n" + item.HeadComment, "\n")
+			}
+		}
+	}
+	for _, child := range node.Content {
+		markBlankLines(child, lines)
+	}
+}
+
+// restoreBlankLines turns the markers back into blank lines, unless the encoder already wrote one there
+func restoreBlankLines(encoded []byte) []byte {
+	var buffer bytes.Buffer
+	last := 0
+	for _, match := range blankLineMarker.FindAllIndex(encoded, -1) {
+		buffer.Write(encoded[last:match[0]])
+		written := buffer.Bytes()
+		if len(written) > 0 && !bytes.HasSuffix(written, []byte("\n\n")) {
+			buffer.WriteString("\n")
+		}
+		last = match[1]
+	}
+	buffer.Write(encoded[last:])
+	return buffer.Bytes()
+}
+
+func encodeYaml(documents []*yaml.Node, indent int) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(indent)
+	for _, document := range documents {
+		if err := encoder.Encode(document); err != nil {
+			return nil, err
+		}
+	}
+	err := encoder.Close()
+	return restoreBlankLines(buffer.Bytes()), err
+}
+
+// jsonSource is the file a JSON document was parsed from: its values left untouched by the patch are written back
+// as they were, and the members of the changed objects and arrays keep their spacing and comments
+type jsonSource struct {
+	data    []byte
+	spans   map[*yaml.Node]*jsonSpan
+	// the members of the objects by key node, and of the arrays by item node
+	members map[*yaml.Node]jsonMember
+	before  map[*yaml.Node]interface{}
+}
+
+func newJsonSource(data []byte, document *yaml.Node) (*jsonSource, error) {
+	span, err := scanJson(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	source := &jsonSource{data, map[*yaml.Node]*jsonSpan{}, map[*yaml.Node]jsonMember{}, map[*yaml.Node]interface{}{}}
+	return source, source.link(content(document), span)
+}
+
+// link maps the nodes to their spans, the two trees being parsed from the same file
+func (S *jsonSource) link(node *yaml.Node, span *jsonSpan) error {
+	value, err := plain(node)
+	if err != nil {
+		return err
+	}
+	S.spans[node], S.before[node] = span, value
+	switch node.Kind {
+	case yaml.MappingNode:
+		if len(node.Content) != 2 * len(span.members) {
+			return nil
+		}
+		for i, member := range span.members {
+			S.members[node.Content[2 * i]] = member
+			if err := S.link(node.Content[2 * i + 1], member.value); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		if len(node.Content) != len(span.members) {
+			return nil
+		}
+		for i, member := range span.members {
+			S.members[node.Content[i]] = member
+			if err := S.link(node.Content[i], member.value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// original returns the bytes a node was parsed from, when its value is left unchanged
+func (S *jsonSource) original(node *yaml.Node) ([]byte, bool) {
+	if S == nil {
+		return nil, false
+	}
+	span, ok := S.spans[node]
+	if !ok {
+		return nil, false
+	}
+	value, err := plain(node)
+	if err != nil || !reflect.DeepEqual(value, S.before[node]) {
+		return nil, false
+	}
+	return S.data[span.start:span.end], true
+}
+
+// compact tells whether a container was written on a single line, and with spaces inside its brackets
+func (S *jsonSource) compact(node *yaml.Node) (bool, string) {
+	if S == nil {
+		return false, ""
+	}
+	span, ok := S.spans[node]
+	if !ok || bytes.IndexByte(S.data[span.start:span.end], '\n') != -1 {
+		return false, ""
+	}
+	if span.end - span.start > 2 && S.data[span.start + 1] == ' ' {
+		return true, " "
+	}
+	return true, ""
+}
+
+// lead returns the whitespace and comments preceding a member, when they start it on a new line
+func (S *jsonSource) lead(node *yaml.Node) ([]byte, bool) {
+	if S == nil {
+		return nil, false
+	}
+	member, ok := S.members[node]
+	if !ok {
+		return nil, false
+	}
+	start := member.keyStart
+	if member.keyEnd == 0 {
+		start = member.value.start
+	}
+	lead := S.data[member.lead:start]
+	return lead, bytes.IndexByte(lead, '\n') != -1
+}
+
+// encodeJson writes a document as JSON, keeping the order of its keys and, with a source, its untouched parts
+func encodeJson(document *yaml.Node, source *jsonSource, indent string, trailingNewline bool) ([]byte, error) {
+	var buffer bytes.Buffer
+	root := content(document)
+	if source != nil {
+		// the comments and blank lines around the root are kept too
+		span := source.spans[root]
+		buffer.Write(source.data[:span.start])
+		if err := source.write(&buffer, root, indent, 1); err != nil {
+			return nil, err
+		}
+		buffer.Write(source.data[span.end:])
+		return buffer.Bytes(), nil
+	}
+	if err := source.write(&buffer, root, indent, 1); err != nil {
+		return nil, err
+	}
+	if trailingNewline {
+		buffer.WriteString("\n")
+	}
+	return buffer.Bytes(), nil
+}
+
+func (S *jsonSource) write(buffer *bytes.Buffer, node *yaml.Node, indent string, depth int) error {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if original, ok := S.original(node); ok {
+		buffer.Write(original)
+		return nil
+	}
+	if node.Kind != yaml.MappingNode && node.Kind != yaml.SequenceNode {
+		return writeScalar(buffer, node)
+	}
+
+	opening, closing, step := "[", "]", 1
+	if node.Kind == yaml.MappingNode {
+		opening, closing, step = "{", "}", 2
+	}
+	if len(node.Content) == 0 {
+		buffer.WriteString(opening + closing)
+		return nil
+	}
+	compact, padding := S.compact(node)
+	newline := "\n" + strings.Repeat(indent, depth)
+	buffer.WriteString(opening)
+	for i := 0; i < len(node.Content); i += step {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		if compact && i > 0 {
+			buffer.WriteString(" ")
+		} else if compact {
+			buffer.WriteString(padding)
+		} else if lead, ok := S.lead(node.Content[i]); ok {
+			buffer.Write(lead)
+		} else {
+			buffer.WriteString(newline)
+		}
+		value := node.Content[i]
+		if node.Kind == yaml.MappingNode {
+			if err := S.writeKey(buffer, node.Content[i]); err != nil {
+				return err
+			}
+			buffer.WriteString(": ")
+			value = node.Content[i + 1]
+		}
+		if err := S.write(buffer, value, indent, depth + 1); err != nil {
+			return err
+		}
+	}
+	if compact {
+		buffer.WriteString(padding + closing)
+	} else {
+		buffer.WriteString("\n" + strings.Repeat(indent, depth - 1) + closing)
+	}
+	return nil
+}
+
+// writeKey writes the key of a member, as it was when it comes from the file
+func (S *jsonSource) writeKey(buffer *bytes.Buffer, key *yaml.Node) error {
+	if S != nil {
+		if member, ok := S.members[key]; ok {
+			buffer.Write(S.data[member.keyStart:member.keyEnd])
+			return nil
+		}
+	}
+	return writeString(buffer, key.Value)
+}
+
+func writeString(buffer *bytes.Buffer, value string) error {
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	// the encoder ends every value with a newline
+	buffer.Truncate(buffer.Len() - 1)
+	return nil
+}
+
+func writeScalar(buffer *bytes.Buffer, node *yaml.Node) error {
+	switch node.ShortTag() {
+	case "!!null":
+		buffer.WriteString("null")
+	case "!!bool", "!!int", "!!float":
+		// the numbers of JSON files are written as they were, the YAML ones converted
+		if json.Valid([]byte(node.Value)) {
+			buffer.WriteString(node.Value)
+			return nil
+		}
+		value, err := plain(node)
+		if err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		buffer.Write(encoded)
+	default:
+		return writeString(buffer, node.Value)
+	}
+	return nil
+}
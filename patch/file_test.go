@@ -0,0 +1,210 @@
+package patch
+
+import (
+	"context"
+	"github.com/transcovo/foreachrepo/tasks"
+	"github.com/transcovo/foreachrepo/vfs"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExecuteJson(t *testing.T) {
+	files := vfs.NewMemory(map[string][]byte{"tsconfig.json": []byte(`{
+    "compilerOptions": {"target": "es5", "strict": false},
+    "include": ["src"]
+}
+`)})
+	task, err := NewTask("tsconfig.json", MODE_JSON_PATCH, []byte(`[
+		{"op": "replace", "path": "/compilerOptions/strict", "value": true},
+		{"op": "add", "path": "/exclude", "value": ["node_modules", "<dist>"]}
+	]`))
+	assert.Nil(t, err)
+
+	assert.Nil(t, task.Execute(context.Background(), files))
+	content, _ := files.ReadFile("tsconfig.json")
+	assert.Equal(t, `{
+    "compilerOptions": {"target": "es5", "strict": true},
+    "include": ["src"],
+    "exclude": [
+        "node_modules",
+        "<dist>"
+    ]
+}
+`, string(content))
+
+	err = task.Execute(context.Background(), files)
+	assert.Equal(t, "tsconfig.json is already up to date", err.Error())
+}
+
+func TestExecuteJsonc(t *testing.T) {
+	files := vfs.NewMemory(map[string][]byte{"tsconfig.json": []byte(`/* Shared by the services */
+{
+	// the build
+	"compilerOptions": {
+		"target": "es5", // old browsers
+		"paths": {"@app/*": ["src/*"]},
+
+		/* to be enabled */
+		"strict": false,
+	},
+
+	"include": ["src", "http://example.com/*.d.ts"],
+	"files": []
+}
+`)})
+	task, err := NewTask("tsconfig.json", MODE_MERGE_PATCH, []byte(`{"compilerOptions": {"strict": true, "noEmit": true}, "files": null}`))
+	assert.Nil(t, err)
+
+	assert.Nil(t, task.Execute(context.Background(), files))
+	content, _ := files.ReadFile("tsconfig.json")
+	assert.Equal(t, `/* Shared by the services */
+{
+	// the build
+	"compilerOptions": {
+		"target": "es5", // old browsers
+		"paths": {"@app/*": ["src/*"]},
+
+		/* to be enabled */
+		"strict": true,
+		"noEmit": true
+	},
+
+	"include": ["src", "http://example.com/*.d.ts"]
+}
+`, string(content))
+}
+
+func TestExecuteReplace(t *testing.T) {
+	files := vfs.NewMemory(map[string][]byte{
+		"package.json": []byte(`{
+  "name": "api",
+  "version": "1.0.0",
+  "main": "index.js"
+}
+`),
+		".github/workflows/ci.yml": []byte(`jobs:
+  build:
+    # the runner
+    runs-on: ubuntu-18.04 # the oldest supported
+    steps:
+      - run: npm test
+`),
+	})
+	task, err := NewTask("package.json", MODE_JSON_PATCH, []byte(`[{"op": "replace", "path": "/version", "value": "2.0.0"}]`))
+	assert.Nil(t, err)
+	assert.Nil(t, task.Execute(context.Background(), files))
+	content, _ := files.ReadFile("package.json")
+	assert.Equal(t, `{
+  "name": "api",
+  "version": "2.0.0",
+  "main": "index.js"
+}
+`, string(content))
+
+	task, err = NewTask(".github/workflows/ci.yml", MODE_JSON_PATCH,
+		[]byte(`[{"op": "replace", "path": "/jobs/build/runs-on", "value": "ubuntu-20.04"}]`))
+	assert.Nil(t, err)
+	assert.Nil(t, task.Execute(context.Background(), files))
+	content, _ = files.ReadFile(".github/workflows/ci.yml")
+	assert.Equal(t, `jobs:
+  build:
+    # the runner
+    runs-on: ubuntu-20.04 # the oldest supported
+    steps:
+      - run: npm test
+`, string(content))
+}
+
+func TestExecuteEscapedSlashes(t *testing.T) {
+	files := vfs.NewMemory(map[string][]byte{"composer.json": []byte(`{"homepage": "https:\/\/example.com", "license": "MIT"}`)})
+	task, err := NewTask("composer.json", MODE_JSON_PATCH, []byte(`[{"op": "add", "path": "/issues", "value": "https:\/\/example.com\/issues"}]`))
+	assert.Nil(t, err)
+
+	assert.Nil(t, task.Execute(context.Background(), files))
+	content, _ := files.ReadFile("composer.json")
+	assert.Equal(t, `{"homepage": "https:\/\/example.com", "license": "MIT", "issues": "https://example.com/issues"}`, string(content))
+}
+
+func TestExecuteYamlBlankLines(t *testing.T) {
+	files := vfs.NewMemory(map[string][]byte{"docker-compose.yml": []byte(`version: "3"
+
+# the services
+services:
+  api:
+    image: api:1.0
+
+  worker:
+    image: worker:1.0
+    environment:
+      - QUEUE=jobs
+
+      - WORKERS=2
+
+volumes:
+  data: {}
+`)})
+	task, err := NewTask("docker-compose.yml", MODE_JSON_PATCH, []byte(`[{"op": "replace", "path": "/services/api/image", "value": "api:2.0"}]`))
+	assert.Nil(t, err)
+
+	assert.Nil(t, task.Execute(context.Background(), files))
+	content, _ := files.ReadFile("docker-compose.yml")
+	assert.Equal(t, `version: "3"
+
+# the services
+services:
+  api:
+    image: api:2.0
+
+  worker:
+    image: worker:1.0
+    environment:
+      - QUEUE=jobs
+
+      - WORKERS=2
+
+volumes:
+  data: {}
+`, string(content))
+}
+
+func TestExecuteYaml(t *testing.T) {
+	files := vfs.NewMemory(map[string][]byte{".travis.yml": []byte(`# CI configuration
+language: node_js
+node_js:
+  - "8" # the LTS
+script: npm test
+`)})
+	task, err := NewTask(".travis.yml", MODE_MERGE_PATCH, []byte(`{"node_js": ["10"], "cache": {"directories": ["node_modules"]}}`))
+	assert.Nil(t, err)
+
+	assert.Nil(t, task.Execute(context.Background(), files))
+	content, _ := files.ReadFile(".travis.yml")
+	assert.Equal(t, `# CI configuration
+language: node_js
+node_js:
+  - "10"
+script: npm test
+cache:
+  directories:
+    - node_modules
+`, string(content))
+}
+
+func TestExecuteErrors(t *testing.T) {
+	files := vfs.NewMemory(map[string][]byte{"renovate.json": []byte(`{"extends": ["config:base"]`)})
+	task, _ := NewTask("renovate.json", MODE_MERGE_PATCH, []byte(`{"automerge": true}`))
+	err := task.Execute(context.Background(), files)
+	assert.IsType(t, &tasks.TaskFailure{}, err)
+
+	task, _ = NewTask(".eslintrc.json", MODE_MERGE_PATCH, []byte(`{"root": true}`))
+	err = task.Execute(context.Background(), files)
+	assert.Equal(t, "No .eslintrc.json found", err.Error())
+
+	files = vfs.NewMemory(map[string][]byte{"package.json": []byte(`{"private": false}`)})
+	task, _ = NewTask("package.json", MODE_JSON_PATCH, []byte(`[{"op": "test", "path": "/private", "value": true}]`))
+	err = task.Execute(context.Background(), files)
+	assert.IsType(t, &TestFailed{}, err)
+
+	_, err = NewTask("package.json", "strategic", []byte(`{}`))
+	assert.Contains(t, err.Error(), "Unknown patch type strategic")
+}
@@ -0,0 +1,220 @@
+package patch
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// JSON files may hold comments and trailing commas, as tsconfig.json does. They are parsed as YAML once their
+// comments are blanked out, and scanned for the spans of their values, so that the untouched values are written
+// back as they were.
+
+type InvalidJson struct {
+	offset int
+	reason string
+}
+
+func (I *InvalidJson) Error() string {
+	return "Invalid JSON at offset " + strconv.Itoa(I.offset) + ": " + I.reason
+}
+
+// jsonSpan is where a value lies in a file. The members of objects and the items of arrays are listed with the
+// offset their leading whitespace and comments start at, right after the opening bracket or the previous comma.
+type jsonSpan struct {
+	start   int
+	end     int
+	members []jsonMember
+}
+
+type jsonMember struct {
+	lead     int
+	keyStart int
+	keyEnd   int
+	value    *jsonSpan
+}
+
+// commentEnd returns the end of the comment starting at i, or i when there is none
+func commentEnd(content []byte, i int) int {
+	if i + 1 >= len(content) || content[i] != '/' {
+		return i
+	}
+	switch content[i + 1] {
+	case '/':
+		end := bytes.IndexByte(content[i:], '\n')
+		if end == -1 {
+			return len(content)
+		}
+		return i + end
+	case '*':
+		end := bytes.Index(content[i + 2:], []byte("*/"))
+		if end == -1 {
+			return len(content)
+		}
+		return i + 2 + end + 2
+	}
+	return i
+}
+
+// stringEnd returns the end of the string starting at i, past its closing quote
+func stringEnd(content []byte, i int) (int, error) {
+	for j := i + 1; j < len(content); j++ {
+		switch content[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1, nil
+		}
+	}
+	return 0, &InvalidJson{i, "unterminated string"}
+}
+
+// stripComments blanks the comments out, their newlines excepted, so that the offsets and lines are kept
+func stripComments(content []byte) ([]byte, error) {
+	stripped := append([]byte{}, content...)
+	for i := 0; i < len(stripped); {
+		if stripped[i] == '"' {
+			end, err := stringEnd(stripped, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			continue
+		}
+		end := commentEnd(stripped, i)
+		if end == i {
+			i++
+			continue
+		}
+		for ; i < end; i++ {
+			if stripped[i] != '\n' {
+				stripped[i] = ' '
+			}
+		}
+	}
+	return stripped, nil
+}
+
+// unescapeSlashes unescapes the slashes of the strings, JSON allowing to escape them and YAML not, the comments
+// being copied as they are
+func unescapeSlashes(content []byte) ([]byte, error) {
+	unescaped := make([]byte, 0, len(content))
+	for i := 0; i < len(content); {
+		if content[i] != '"' {
+			end := commentEnd(content, i)
+			if end == i {
+				end++
+			}
+			unescaped = append(unescaped, content[i:end]...)
+			i = end
+			continue
+		}
+		end, err := stringEnd(content, i)
+		if err != nil {
+			return nil, err
+		}
+		for j := i; j < end; j++ {
+			if content[j] == '\\' {
+				if content[j + 1] != '/' {
+					unescaped = append(unescaped, '\\')
+				}
+				j++
+			}
+			unescaped = append(unescaped, content[j])
+		}
+		i = end
+	}
+	return unescaped, nil
+}
+
+// skipTrivia skips the whitespace and comments starting at i
+func skipTrivia(content []byte, i int) int {
+	for i < len(content) {
+		switch content[i] {
+		case ' ', '\t', '\r', '\n':
+			i++
+		case '/':
+			end := commentEnd(content, i)
+			if end == i {
+				return i
+			}
+			i = end
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// scanJson returns the span of the value starting at i, its leading trivia skipped
+func scanJson(content []byte, i int) (*jsonSpan, error) {
+	i = skipTrivia(content, i)
+	if i >= len(content) {
+		return nil, &InvalidJson{i, "unexpected end"}
+	}
+	span := &jsonSpan{start: i}
+	switch content[i] {
+	case '{', '[':
+		closing := byte('}')
+		if content[i] == '[' {
+			closing = ']'
+		}
+		lead := i + 1
+		for {
+			j := skipTrivia(content, lead)
+			if j >= len(content) {
+				return nil, &InvalidJson{j, "unexpected end"}
+			}
+			if content[j] == closing {
+				span.end = j + 1
+				return span, nil
+			}
+			member := jsonMember{lead: lead}
+			if closing == '}' {
+				if content[j] != '"' {
+					return nil, &InvalidJson{j, "expected a key"}
+				}
+				keyEnd, err := stringEnd(content, j)
+				if err != nil {
+					return nil, err
+				}
+				member.keyStart, member.keyEnd = j, keyEnd
+				j = skipTrivia(content, keyEnd)
+				if j >= len(content) || content[j] != ':' {
+					return nil, &InvalidJson{j, "expected a colon"}
+				}
+				j++
+			}
+			value, err := scanJson(content, j)
+			if err != nil {
+				return nil, err
+			}
+			member.value = value
+			span.members = append(span.members, member)
+			j = skipTrivia(content, value.end)
+			if j < len(content) && content[j] == ',' {
+				lead = j + 1
+			} else {
+				lead = j
+				if j >= len(content) || content[j] != closing {
+					return nil, &InvalidJson{j, "expected a comma"}
+				}
+			}
+		}
+	case '"':
+		end, err := stringEnd(content, i)
+		if err != nil {
+			return nil, err
+		}
+		span.end = end
+	default:
+		end := i
+		for end < len(content) && bytes.IndexByte([]byte(",:]} \t\r\n/{[\""), content[end]) == -1 {
+			end++
+		}
+		if end == i {
+			return nil, &InvalidJson{i, "unexpected " + string(content[i])}
+		}
+		span.end = end
+	}
+	return span, nil
+}
@@ -0,0 +1,39 @@
+package patch
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStripComments(t *testing.T) {
+	stripped, err := stripComments([]byte("{\"url\": \"http://example.com\", // the site\n/* a\nb */ \"a\": 1}"))
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"url\": \"http://example.com\",            \n    \n     \"a\": 1}", string(stripped))
+
+	_, err = stripComments([]byte(`{"a": "unterminated}`))
+	assert.Equal(t, "Invalid JSON at offset 6: unterminated string", err.Error())
+}
+
+func TestUnescapeSlashes(t *testing.T) {
+	unescaped, err := unescapeSlashes([]byte(`{"url": "http:\/\/example.com", "path": "C:\\/x\"y"} // a\/b`))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"url": "http://example.com", "path": "C:\\/x\"y"} // a\/b`, string(unescaped))
+}
+
+func TestScanJson(t *testing.T) {
+	content := []byte("{\n  \"a\": [1, {\"b\": null}], // trailing\n  \"c\": \"d\",\n}")
+	span, err := scanJson(content, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, span.start)
+	assert.Equal(t, len(content), span.end)
+	assert.Len(t, span.members, 2)
+	assert.Equal(t, `"a"`, string(content[span.members[0].keyStart:span.members[0].keyEnd]))
+	assert.Equal(t, `[1, {"b": null}]`, string(content[span.members[0].value.start:span.members[0].value.end]))
+	assert.Equal(t, " // trailing\n  ", string(content[span.members[1].lead:span.members[1].keyStart]))
+	assert.Len(t, span.members[0].value.members, 2)
+
+	_, err = scanJson([]byte(`{"a" 1}`), 0)
+	assert.Equal(t, "Invalid JSON at offset 5: expected a colon", err.Error())
+	_, err = scanJson([]byte(`[1 2]`), 0)
+	assert.Equal(t, "Invalid JSON at offset 3: expected a comma", err.Error())
+}
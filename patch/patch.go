@@ -0,0 +1,454 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The patches work on yaml.Node trees, which keep the order of the keys and the comments of the files, JSON files
+// being parsed as YAML too.
+
+type InvalidPointer struct {
+	pointer string
+}
+
+func (I *InvalidPointer) Error() string {
+	return "Invalid JSON pointer " + I.pointer
+}
+
+type PathNotFound struct {
+	pointer string
+}
+
+func (P *PathNotFound) Error() string {
+	return "No value at " + P.pointer
+}
+
+type InvalidOperation struct {
+	index  int
+	reason string
+}
+
+func (I *InvalidOperation) Error() string {
+	return "Invalid patch operation #" + strconv.Itoa(I.index) + ": " + I.reason
+}
+
+// TestFailed is returned when a test operation doesn't match, the repo not needing the patch
+type TestFailed struct {
+	pointer string
+}
+
+func (T *TestFailed) Error() string {
+	return "Patch test op failed at " + T.pointer
+}
+
+// Operation is an operation of a RFC 6902 JSON Patch
+type Operation struct {
+	Op    string
+	Path  string
+	From  string
+	Value *yaml.Node
+}
+
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// content skips the document node wrapping the root of parsed files
+func content(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.DocumentNode && len(node.Content) == 1 {
+		node = node.Content[0]
+	}
+	return node
+}
+
+// decodeSource parses a patch written in JSON or YAML, the slashes of the JSON ones being unescaped first
+func decodeSource(source []byte, document *yaml.Node) error {
+	trimmed := bytes.TrimSpace(source)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		unescaped, err := unescapeSlashes(source)
+		if err != nil {
+			return err
+		}
+		source = unescaped
+	}
+	return yaml.Unmarshal(source, document)
+}
+
+// ParseOperations reads a JSON Patch, written in JSON or YAML
+func ParseOperations(source []byte) ([]Operation, error) {
+	var document yaml.Node
+	if err := decodeSource(source, &document); err != nil {
+		return nil, err
+	}
+	root := content(&document)
+	if root.Kind != yaml.SequenceNode {
+		return nil, &InvalidOperation{0, "a JSON Patch is an array of operations"}
+	}
+	operations := []Operation{}
+	for i, node := range root.Content {
+		if node.Kind != yaml.MappingNode {
+			return nil, &InvalidOperation{i, "not an object"}
+		}
+		operation := Operation{}
+		for j := 0; j < len(node.Content); j += 2 {
+			key, value := node.Content[j].Value, node.Content[j + 1]
+			switch key {
+			case "op":
+				operation.Op = value.Value
+			case "path":
+				operation.Path = value.Value
+			case "from":
+				operation.From = value.Value
+			case "value":
+				operation.Value = value
+			}
+		}
+		switch operation.Op {
+		case "add", "replace", "test":
+			if operation.Value == nil {
+				return nil, &InvalidOperation{i, operation.Op + " needs a value"}
+			}
+		case "move", "copy":
+			if _, err := splitPointer(operation.From); err != nil {
+				return nil, err
+			}
+		case "remove":
+		default:
+			return nil, &InvalidOperation{i, "unknown op " + operation.Op}
+		}
+		if _, err := splitPointer(operation.Path); err != nil {
+			return nil, err
+		}
+		operations = append(operations, operation)
+	}
+	return operations, nil
+}
+
+// splitPointer splits a RFC 6901 JSON pointer in its unescaped tokens
+func splitPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, &InvalidPointer{pointer}
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+func keyIndex(mapping *yaml.Node, key string) int {
+	for i := 0; i < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func arrayIndex(sequence *yaml.Node, token string, pointer string) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || strconv.Itoa(index) != token {
+		return 0, &InvalidPointer{pointer}
+	}
+	return index, nil
+}
+
+// get returns the node a pointer refers to
+func get(root *yaml.Node, pointer string) (*yaml.Node, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	node := root
+	for _, token := range tokens {
+		switch node.Kind {
+		case yaml.MappingNode:
+			i := keyIndex(node, token)
+			if i == -1 {
+				return nil, &PathNotFound{pointer}
+			}
+			node = node.Content[i + 1]
+		case yaml.SequenceNode:
+			i, err := arrayIndex(node, token, pointer)
+			if err != nil {
+				return nil, err
+			}
+			if i >= len(node.Content) {
+				return nil, &PathNotFound{pointer}
+			}
+			node = node.Content[i]
+		default:
+			return nil, &PathNotFound{pointer}
+		}
+	}
+	return node, nil
+}
+
+// parent returns the container of the value a pointer refers to, and the last token of the pointer
+func parent(root *yaml.Node, pointer string) (*yaml.Node, string, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(tokens) == 0 {
+		return nil, "", &InvalidPointer{pointer}
+	}
+	// the escaped tokens have no slash, the last one starts after the last slash
+	container, err := get(root, pointer[:strings.LastIndex(pointer, "/")])
+	if err != nil {
+		return nil, "", err
+	}
+	if container.Kind != yaml.MappingNode && container.Kind != yaml.SequenceNode {
+		return nil, "", &PathNotFound{pointer}
+	}
+	return container, tokens[len(tokens) - 1], nil
+}
+
+func add(root *yaml.Node, pointer string, value *yaml.Node) error {
+	if pointer == "" {
+		*root = *value
+		return nil
+	}
+	container, token, err := parent(root, pointer)
+	if err != nil {
+		return err
+	}
+	if container.Kind == yaml.MappingNode {
+		if i := keyIndex(container, token); i != -1 {
+			container.Content[i + 1] = value
+			return nil
+		}
+		container.Content = append(container.Content, scalar(token), value)
+		return nil
+	}
+	index := len(container.Content)
+	if token != "-" {
+		index, err = arrayIndex(container, token, pointer)
+		if err != nil {
+			return err
+		}
+		if index > len(container.Content) {
+			return &PathNotFound{pointer}
+		}
+	}
+	container.Content = append(container.Content, nil)
+	copy(container.Content[index + 1:], container.Content[index:])
+	container.Content[index] = value
+	return nil
+}
+
+func remove(root *yaml.Node, pointer string) (*yaml.Node, error) {
+	container, token, err := parent(root, pointer)
+	if err != nil {
+		return nil, err
+	}
+	if container.Kind == yaml.MappingNode {
+		i := keyIndex(container, token)
+		if i == -1 {
+			return nil, &PathNotFound{pointer}
+		}
+		removed := container.Content[i + 1]
+		container.Content = append(container.Content[:i], container.Content[i + 2:]...)
+		return removed, nil
+	}
+	i, err := arrayIndex(container, token, pointer)
+	if err != nil {
+		return nil, err
+	}
+	if i >= len(container.Content) {
+		return nil, &PathNotFound{pointer}
+	}
+	removed := container.Content[i]
+	container.Content = append(container.Content[:i], container.Content[i + 1:]...)
+	return removed, nil
+}
+
+// keepComments moves the comments of a replaced value to its replacement, unless it has its own
+func keepComments(replaced *yaml.Node, value *yaml.Node) *yaml.Node {
+	if value.HeadComment == "" && value.LineComment == "" && value.FootComment == "" {
+		value.HeadComment, value.LineComment, value.FootComment =
+			replaced.HeadComment, replaced.LineComment, replaced.FootComment
+	}
+	return value
+}
+
+// replace swaps the value a pointer refers to in place, so that its key keeps its position and comments
+func replace(root *yaml.Node, pointer string, value *yaml.Node) error {
+	if pointer == "" {
+		*root = *value
+		return nil
+	}
+	container, token, err := parent(root, pointer)
+	if err != nil {
+		return err
+	}
+	if container.Kind == yaml.MappingNode {
+		i := keyIndex(container, token)
+		if i == -1 {
+			return &PathNotFound{pointer}
+		}
+		container.Content[i + 1] = keepComments(container.Content[i + 1], value)
+		return nil
+	}
+	i, err := arrayIndex(container, token, pointer)
+	if err != nil {
+		return err
+	}
+	if i >= len(container.Content) {
+		return &PathNotFound{pointer}
+	}
+	container.Content[i] = keepComments(container.Content[i], value)
+	return nil
+}
+
+// clone copies a node deeply, so that copied values don't share their children
+func clone(node *yaml.Node) *yaml.Node {
+	copied := *node
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		copied.Content[i] = clone(child)
+	}
+	return &copied
+}
+
+// plain decodes a node to the value JSON would hold, for comparisons: numbers become float64
+func plain(node *yaml.Node) (interface{}, error) {
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(toJsonValue(value))
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	err = json.Unmarshal(encoded, &decoded)
+	return decoded, err
+}
+
+// toJsonValue converts the maps decoded from YAML, keyed by any value, to maps JSON can encode
+func toJsonValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, child := range typed {
+			typed[key] = toJsonValue(child)
+		}
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for key, child := range typed {
+			converted[toString(key)] = toJsonValue(child)
+		}
+		return converted
+	case []interface{}:
+		for i, child := range typed {
+			typed[i] = toJsonValue(child)
+		}
+	}
+	return value
+}
+
+func toString(value interface{}) string {
+	encoded, _ := json.Marshal(value)
+	return strings.Trim(string(encoded), `"`)
+}
+
+func equal(a *yaml.Node, b *yaml.Node) (bool, error) {
+	plainA, err := plain(a)
+	if err != nil {
+		return false, err
+	}
+	plainB, err := plain(b)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(plainA, plainB), nil
+}
+
+// Apply applies the operations of a JSON Patch to a document, in order, stopping at the first failing one
+func Apply(document *yaml.Node, operations []Operation) error {
+	root := content(document)
+	for _, operation := range operations {
+		var err error
+		switch operation.Op {
+		case "add":
+			err = add(root, operation.Path, clone(operation.Value))
+		case "remove":
+			_, err = remove(root, operation.Path)
+		case "replace":
+			err = replace(root, operation.Path, clone(operation.Value))
+		case "move":
+			var moved *yaml.Node
+			if strings.HasPrefix(operation.Path + "/", operation.From + "/") && operation.Path != operation.From {
+				return &InvalidPointer{operation.Path + " inside " + operation.From}
+			}
+			if moved, err = remove(root, operation.From); err == nil {
+				err = add(root, operation.Path, moved)
+			}
+		case "copy":
+			var copied *yaml.Node
+			if copied, err = get(root, operation.From); err == nil {
+				err = add(root, operation.Path, clone(copied))
+			}
+		case "test":
+			var tested *yaml.Node
+			if tested, err = get(root, operation.Path); err == nil {
+				var matches bool
+				matches, err = equal(tested, operation.Value)
+				if err == nil && !matches {
+					err = &TestFailed{operation.Path}
+				}
+			}
+			if _, notFound := err.(*PathNotFound); notFound {
+				err = &TestFailed{operation.Path}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
+
+// Merge applies a RFC 7386 JSON Merge Patch to a document: objects are merged recursively, null values removing
+// their keys, and any other value replacing the target
+func Merge(document *yaml.Node, patch *yaml.Node) {
+	root := content(document)
+	*root = *merge(root, content(patch))
+}
+
+func merge(target *yaml.Node, patch *yaml.Node) *yaml.Node {
+	if patch.Kind != yaml.MappingNode {
+		return clone(patch)
+	}
+	if target == nil || target.Kind != yaml.MappingNode {
+		target = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	for i := 0; i < len(patch.Content); i += 2 {
+		key, value := patch.Content[i].Value, patch.Content[i + 1]
+		j := keyIndex(target, key)
+		if isNull(value) {
+			if j != -1 {
+				target.Content = append(target.Content[:j], target.Content[j + 2:]...)
+			}
+			continue
+		}
+		if j == -1 {
+			target.Content = append(target.Content, scalar(key), merge(nil, value))
+		} else {
+			target.Content[j + 1] = merge(target.Content[j + 1], value)
+		}
+	}
+	return target
+}
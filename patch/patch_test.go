@@ -0,0 +1,101 @@
+package patch
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"testing"
+)
+
+func parse(t *testing.T, source string) *yaml.Node {
+	document := &yaml.Node{}
+	assert.Nil(t, yaml.Unmarshal([]byte(source), document))
+	return document
+}
+
+func applied(t *testing.T, source string, patch string) (interface{}, error) {
+	document := parse(t, source)
+	operations, err := ParseOperations([]byte(patch))
+	assert.Nil(t, err)
+	if err := Apply(document, operations); err != nil {
+		return nil, err
+	}
+	return plain(document)
+}
+
+func TestApply(t *testing.T) {
+	value, err := applied(t, `{"a": {"b": 1}, "list": [1, 2], "old": "x", "a/b": true}`, `[
+		{"op": "add", "path": "/a/c", "value": {"d": [true]}},
+		{"op": "add", "path": "/list/1", "value": 3},
+		{"op": "add", "path": "/list/-", "value": 4},
+		{"op": "remove", "path": "/a/b"},
+		{"op": "replace", "path": "/old", "value": "y"},
+		{"op": "move", "from": "/old", "path": "/new"},
+		{"op": "copy", "from": "/list/0", "path": "/first"},
+		{"op": "test", "path": "/a~1b", "value": true},
+		{"op": "test", "path": "/list", "value": [1, 3, 2, 4]}
+	]`)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"a": map[string]interface{}{"c": map[string]interface{}{"d": []interface{}{true}}},
+		"list": []interface{}{1.0, 3.0, 2.0, 4.0},
+		"new": "y",
+		"first": 1.0,
+		"a/b": true,
+	}, value)
+}
+
+func TestApplyReplaceInPlace(t *testing.T) {
+	document := parse(t, `{"a": 1, "b": [1, 2], "c": 3}`)
+	operations, err := ParseOperations([]byte(`[
+		{"op": "replace", "path": "/a", "value": 2},
+		{"op": "replace", "path": "/b/0", "value": 0}
+	]`))
+	assert.Nil(t, err)
+	assert.Nil(t, Apply(document, operations))
+	root := content(document)
+	assert.Equal(t, "a", root.Content[0].Value)
+	assert.Equal(t, "2", root.Content[1].Value)
+	assert.Equal(t, "0", root.Content[3].Content[0].Value)
+
+	operations, _ = ParseOperations([]byte(`[{"op": "replace", "path": "/d", "value": 4}]`))
+	assert.Equal(t, "No value at /d", Apply(document, operations).Error())
+}
+
+func TestApplyTestFailed(t *testing.T) {
+	_, err := applied(t, `{"a": 1}`, `[{"op": "test", "path": "/a", "value": 2}, {"op": "remove", "path": "/a"}]`)
+	assert.Equal(t, "Patch test op failed at /a", err.Error())
+	_, err = applied(t, `{"a": 1}`, `[{"op": "test", "path": "/b", "value": 1}]`)
+	assert.IsType(t, &TestFailed{}, err)
+}
+
+func TestApplyErrors(t *testing.T) {
+	_, err := applied(t, `{"a": 1}`, `[{"op": "remove", "path": "/b"}]`)
+	assert.Equal(t, "No value at /b", err.Error())
+	_, err = applied(t, `{"a": [1]}`, `[{"op": "add", "path": "/a/5", "value": 1}]`)
+	assert.Equal(t, "No value at /a/5", err.Error())
+	_, err = applied(t, `{"a": {"b": 1}}`, `[{"op": "move", "from": "/a", "path": "/a/c"}]`)
+	assert.NotNil(t, err)
+}
+
+func TestParseOperationsErrors(t *testing.T) {
+	_, err := ParseOperations([]byte(`{"op": "add"}`))
+	assert.Contains(t, err.Error(), "a JSON Patch is an array of operations")
+	_, err = ParseOperations([]byte(`[{"op": "add", "path": "/a"}]`))
+	assert.Equal(t, "Invalid patch operation #0: add needs a value", err.Error())
+	_, err = ParseOperations([]byte(`[{"op": "rename", "path": "/a"}]`))
+	assert.Equal(t, "Invalid patch operation #0: unknown op rename", err.Error())
+	_, err = ParseOperations([]byte(`[{"op": "remove", "path": "a"}]`))
+	assert.Equal(t, "Invalid JSON pointer a", err.Error())
+}
+
+func TestMerge(t *testing.T) {
+	document := parse(t, `{"a": "b", "c": {"d": "e", "f": "g"}, "list": [1]}`)
+	Merge(document, parse(t, `{"a": "z", "c": {"f": null, "h": {"i": null}}, "list": [2, 3]}`))
+	value, err := plain(document)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"a": "z",
+		"c": map[string]interface{}{"d": "e", "h": map[string]interface{}{}},
+		"list": []interface{}{2.0, 3.0},
+	}, value)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/transcovo/foreachrepo/plugins"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const PLUGINS_EXAMPLES = `. Examples:
+
+List the plugin tasks found in the plugins dir and on the PATH:
+
+$> foreachrepo plugins
+
+Run the LICENSE task of the foreachrepo-task-license plugin:
+
+$> foreachrepo -task LICENSE -param holder=Acme -org transcovo -branch license -message "TECH Add a LICENSE"`
+
+// defaultPluginsDir is FOREACHREPO_PLUGINS_DIR, or ~/.foreachrepo/plugins
+func defaultPluginsDir() string {
+	if dir := os.Getenv("FOREACHREPO_PLUGINS_DIR"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".foreachrepo", "plugins")
+}
+
+// paramMap is a flag accepting repeated name=value pairs
+type paramMap map[string]string
+
+func (p paramMap) String() string {
+	pairs := []string{}
+	for name, value := range p {
+		pairs = append(pairs, name + "=" + value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (p paramMap) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("expected name=value, got %s", value)
+	}
+	p[parts[0]] = parts[1]
+	return nil
+}
+
+// findPlugin returns the plugin of a task, by the suffix of its executable or else its name, nil when none is found.
+// The executable named after the task is the only one described when there is one.
+func findPlugin(pluginsDir string, taskName string) *plugins.Plugin {
+	dirs := plugins.SearchDirs(pluginsDir)
+	if path := plugins.Lookup(dirs, taskName); path != "" {
+		plugin, err := plugins.Describe(context.Background(), path)
+		if err != nil {
+			log.Fatalln(err.Error(), PLUGINS_EXAMPLES)
+		}
+		return plugin
+	}
+	for _, plugin := range plugins.Discover(context.Background(), dirs) {
+		if plugin.Name == taskName || filepath.Base(plugin.Path) == plugins.PREFIX + taskName {
+			return plugin
+		}
+	}
+	return nil
+}
+
+func pluginsCommand(args []string) {
+	flags := flag.NewFlagSet("plugins", flag.ExitOnError)
+	pluginsDir := flags.String("plugins-dir", defaultPluginsDir(),
+		"The directory of the plugin tasks, searched before the PATH, FOREACHREPO_PLUGINS_DIR by default")
+	flags.Parse(args)
+
+	found := plugins.Discover(context.Background(), plugins.SearchDirs(*pluginsDir))
+	if len(found) == 0 {
+		fmt.Println("No " + plugins.PREFIX + "* plugin found in " + *pluginsDir + " nor on the PATH" + PLUGINS_EXAMPLES)
+		return
+	}
+	for _, plugin := range found {
+		fmt.Println(plugin.Name, "(" + plugin.Path + ")")
+		if plugin.Description != "" {
+			fmt.Println("    " + plugin.Description)
+		}
+		for _, flag := range plugin.Flags {
+			line := "    -param " + flag.Name + "=..."
+			if flag.Default != "" {
+				line += " (default " + flag.Default + ")"
+			}
+			if flag.Description != "" {
+				line += ": " + flag.Description
+			}
+			fmt.Println(line)
+		}
+	}
+}
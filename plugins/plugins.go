@@ -0,0 +1,302 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/process"
+	"github.com/transcovo/foreachrepo/tasks"
+	"github.com/transcovo/foreachrepo/vfs"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PREFIX starts the name of the plugin executables, foreachrepo-task-license being the plugin of the license task
+const PREFIX = "foreachrepo-task-"
+
+// PROTOCOL_VERSION is the version of the requests sent to plugins, raised on incompatible changes
+const PROTOCOL_VERSION = 1
+
+// DESCRIBE_TIMEOUT bounds the time plugins have to describe themselves, so that a hung executable can't block a run
+const DESCRIBE_TIMEOUT = 10 * time.Second
+
+// The commands plugins are run with: describe prints their description, run changes the clone of a repo
+const (
+	COMMAND_DESCRIBE = "describe"
+	COMMAND_RUN = "run"
+)
+
+// The statuses of the replies of plugins
+const (
+	STATUS_CHANGED = "changed"
+	STATUS_SKIPPED = "skipped"
+	STATUS_ERROR = "error"
+)
+
+type InvalidPlugin struct {
+	path string
+	err  error
+}
+
+func (I *InvalidPlugin) Error() string {
+	return "Invalid plugin " + I.path + ": " + I.err.Error()
+}
+
+type UnknownParam struct {
+	plugin string
+	param  string
+}
+
+func (U *UnknownParam) Error() string {
+	return "Unknown param " + U.param + " for the " + U.plugin + " plugin"
+}
+
+type NeedsClone struct {
+	plugin string
+}
+
+func (N *NeedsClone) Error() string {
+	return "The " + N.plugin + " plugin needs a clone of the repo"
+}
+
+// Skipped is returned when a plugin left the repo unchanged
+type Skipped struct {
+	messages []string
+}
+
+func (S *Skipped) Error() string {
+	if len(S.messages) == 0 {
+		return "Skipped by the plugin"
+	}
+	return strings.Join(S.messages, ", ")
+}
+
+type PluginError struct {
+	plugin   string
+	messages []string
+}
+
+func (P *PluginError) Error() string {
+	return "The " + P.plugin + " plugin failed: " + strings.Join(P.messages, ", ")
+}
+
+// Flag is a param a plugin accepts
+type Flag struct {
+	Name        string
+	Description string
+	Default     string
+}
+
+// Plugin is an executable implementing a task, described by its reply to the describe command:
+// {"name": "LICENSE", "description": "...", "flags": [{"name": "holder", "description": "...", "default": ""}]}
+type Plugin struct {
+	Path        string
+	Name        string
+	Description string
+	Flags       []Flag
+}
+
+type reply struct {
+	Status   string
+	Messages []string
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir() && info.Mode() & 0111 != 0
+}
+
+// Find lists the plugin executables of the dirs, the first dirs taking precedence for a given executable name
+func Find(dirs []string) []string {
+	found := map[string]bool{}
+	paths := []string{}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			path := filepath.Join(dir, name)
+			if !strings.HasPrefix(name, PREFIX) || found[name] || !isExecutable(path) {
+				continue
+			}
+			found[name] = true
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// SearchDirs are the dirs plugins are looked for in: the plugins dir, then the PATH
+func SearchDirs(pluginsDir string) []string {
+	return append([]string{pluginsDir}, filepath.SplitList(os.Getenv("PATH"))...)
+}
+
+// Lookup returns the executable of the plugin named foreachrepo-task-<name>, or with the name in lower case, from
+// the first dir having it, empty when none has
+func Lookup(dirs []string, name string) string {
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		for _, executable := range []string{PREFIX + name, PREFIX + strings.ToLower(name)} {
+			path := filepath.Join(dir, executable)
+			if isExecutable(path) {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// Describe asks a plugin executable for its name, description and flags, within DESCRIBE_TIMEOUT
+func Describe(ctx context.Context, path string) (*Plugin, error) {
+	ctx, cancel := context.WithTimeout(ctx, DESCRIBE_TIMEOUT)
+	defer cancel()
+	output, err := process.Command(ctx, path, COMMAND_DESCRIBE).Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, &InvalidPlugin{path, errors.New("no description after " + DESCRIBE_TIMEOUT.String())}
+	}
+	if err != nil {
+		return nil, &InvalidPlugin{path, err}
+	}
+	plugin := &Plugin{}
+	if err := json.Unmarshal(output, plugin); err != nil {
+		return nil, &InvalidPlugin{path, err}
+	}
+	if plugin.Name == "" {
+		plugin.Name = strings.TrimPrefix(filepath.Base(path), PREFIX)
+	}
+	plugin.Path = path
+	return plugin, nil
+}
+
+// Discover describes the plugins found in the dirs, sorted by name, logging the ones failing to
+func Discover(ctx context.Context, dirs []string) []*Plugin {
+	plugins := []*Plugin{}
+	for _, path := range Find(dirs) {
+		plugin, err := Describe(ctx, path)
+		if err != nil {
+			log.Println("Ignoring ", err.Error())
+			continue
+		}
+		plugins = append(plugins, plugin)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// Params checks the params given to a plugin, completing them with the defaults of its flags
+func (P *Plugin) Params(given map[string]string) (map[string]string, error) {
+	params := map[string]string{}
+	for _, flag := range P.Flags {
+		params[flag.Name] = flag.Default
+	}
+	for name, value := range given {
+		if _, ok := params[name]; !ok {
+			return nil, &UnknownParam{P.Name, name}
+		}
+		params[name] = value
+	}
+	return params, nil
+}
+
+// Task runs a plugin on the clone of every repo, sending it a JSON request on its stdin:
+// {"version": 1, "dir": "/tmp/clone", "repo": {"name": ..., "full_name": ..., ...}, "params": {"holder": "Acme"}}
+// and reading its JSON reply on its stdout: {"status": "changed", "messages": ["Added LICENSE"]}, the status being
+// changed, skipped or error. Its stderr is logged.
+type Task struct {
+	Plugin   *Plugin
+	Params   map[string]string
+	repo     github.Repo
+	// the messages of the changed reply, for the summary of the repo
+	messages []string
+}
+
+func (T *Task) ForRepo(repo github.Repo) tasks.Task {
+	bound := *T
+	bound.repo = repo
+	bound.messages = nil
+	return &bound
+}
+
+// Summary joins the messages the plugin replied with when it changed the repo
+func (T *Task) Summary() string {
+	return strings.Join(T.messages, ", ")
+}
+
+func (T *Task) request(dir string) ([]byte, error) {
+	topics := T.repo.Topics
+	if topics == nil {
+		topics = []string{}
+	}
+	return json.Marshal(map[string]interface{}{
+		"version": PROTOCOL_VERSION,
+		"dir": dir,
+		"repo": map[string]interface{}{
+			"name": T.repo.Name,
+			"full_name": T.repo.DisplayName(),
+			"owner": T.repo.Owner(),
+			"provider": T.repo.Provider,
+			"git_url": T.repo.GitUrl,
+			"https_url": T.repo.HttpsUrl,
+			"default_branch": T.repo.DefaultBranch,
+			"language": T.repo.Language,
+			"topics": topics,
+		},
+		"params": T.Params,
+	})
+}
+
+func (T *Task) Execute(ctx context.Context, files vfs.FS) error {
+	dir := files.Dir()
+	if dir == "" {
+		return &NeedsClone{T.Plugin.Name}
+	}
+	request, err := T.request(dir)
+	if err != nil {
+		return err
+	}
+
+	cmd := process.Command(ctx, T.Plugin.Path, COMMAND_RUN)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(request)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if stderr.Len() > 0 {
+		log.Print(T.Plugin.Name, ": ", stderr.String())
+	}
+	if err != nil {
+		return &tasks.TaskFailure{Err: &PluginError{T.Plugin.Name, []string{err.Error()}}}
+	}
+
+	result := reply{}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return &tasks.TaskFailure{Err: &PluginError{T.Plugin.Name, []string{"invalid reply, " + err.Error()}}}
+	}
+	switch result.Status {
+	case STATUS_CHANGED:
+		for _, message := range result.Messages {
+			log.Println(T.Plugin.Name, ": ", message)
+		}
+		T.messages = result.Messages
+		return nil
+	case STATUS_SKIPPED:
+		return &Skipped{result.Messages}
+	case STATUS_ERROR:
+		return &tasks.TaskFailure{Err: &PluginError{T.Plugin.Name, result.Messages}}
+	}
+	return &tasks.TaskFailure{Err: &PluginError{T.Plugin.Name, []string{"unknown status " + result.Status}}}
+}
@@ -0,0 +1,143 @@
+package plugins
+
+import (
+	"context"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/tasks"
+	"github.com/transcovo/foreachrepo/vfs"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// SAMPLE_PLUGIN writes a LICENSE with the holder param, skipping the repos having one
+const SAMPLE_PLUGIN = `#!/bin/sh
+if [ "$1" = describe ]; then
+	echo '{"name": "LICENSE", "description": "Adds a LICENSE", "flags": [{"name": "holder", "default": "Acme"}]}'
+	exit 0
+fi
+request=$(cat)
+if [ -f LICENSE ]; then
+	echo '{"status": "skipped", "messages": ["LICENSE already exists"]}'
+	exit 0
+fi
+case "$request" in
+	*'"holder":"fail"'*) echo '{"status": "error", "messages": ["holder rejected"]}'; exit 0;;
+esac
+echo "$request" > LICENSE
+echo "writing LICENSE" >&2
+echo '{"status": "changed", "messages": ["Added LICENSE"]}'
+`
+
+func writePlugin(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0755))
+	return path
+}
+
+func TestFind(t *testing.T) {
+	first, _ := ioutil.TempDir("", "plugins")
+	defer os.RemoveAll(first)
+	second, _ := ioutil.TempDir("", "plugins")
+	defer os.RemoveAll(second)
+	license := writePlugin(t, first, PREFIX + "license", SAMPLE_PLUGIN)
+	writePlugin(t, second, PREFIX + "license", SAMPLE_PLUGIN)
+	readme := writePlugin(t, second, PREFIX + "readme", SAMPLE_PLUGIN)
+	writePlugin(t, second, "other-tool", SAMPLE_PLUGIN)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(second, PREFIX + "notes"), []byte(""), 0644))
+
+	assert.Equal(t, []string{license, readme}, Find([]string{first, "", second, "/nonexistent"}))
+}
+
+func TestLookup(t *testing.T) {
+	first, _ := ioutil.TempDir("", "plugins")
+	defer os.RemoveAll(first)
+	second, _ := ioutil.TempDir("", "plugins")
+	defer os.RemoveAll(second)
+	license := writePlugin(t, second, PREFIX + "license", SAMPLE_PLUGIN)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(first, PREFIX + "license"), []byte(""), 0644))
+
+	assert.Equal(t, license, Lookup([]string{"", first, second}, "LICENSE"))
+	assert.Equal(t, license, Lookup([]string{first, second}, "license"))
+	assert.Equal(t, "", Lookup([]string{first, second}, "readme"))
+}
+
+func TestDescribeTimeout(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "plugins")
+	defer os.RemoveAll(dir)
+	path := writePlugin(t, dir, PREFIX + "hung", "#!/bin/sh\nsleep 5\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100 * time.Millisecond)
+	defer cancel()
+	_, err := Describe(ctx, path)
+	assert.IsType(t, &InvalidPlugin{}, err)
+}
+
+func TestDescribe(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "plugins")
+	defer os.RemoveAll(dir)
+	path := writePlugin(t, dir, PREFIX + "license", SAMPLE_PLUGIN)
+	writePlugin(t, dir, PREFIX + "broken", "#!/bin/sh\necho not json\n")
+
+	plugins := Discover(context.Background(), []string{dir})
+	assert.Len(t, plugins, 1)
+	assert.Equal(t, &Plugin{
+		Path: path,
+		Name: "LICENSE",
+		Description: "Adds a LICENSE",
+		Flags: []Flag{{Name: "holder", Default: "Acme"}},
+	}, plugins[0])
+
+	params, err := plugins[0].Params(map[string]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"holder": "Acme"}, params)
+	_, err = plugins[0].Params(map[string]string{"year": "2018"})
+	assert.Equal(t, "Unknown param year for the LICENSE plugin", err.Error())
+}
+
+func TestExecute(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "plugins")
+	defer os.RemoveAll(dir)
+	clone, _ := ioutil.TempDir("", "clone")
+	defer os.RemoveAll(clone)
+	plugin, err := Describe(context.Background(), writePlugin(t, dir, PREFIX + "license", SAMPLE_PLUGIN))
+	assert.Nil(t, err)
+	task := &Task{Plugin: plugin, Params: map[string]string{"holder": "Acme"}}
+	bound := task.ForRepo(github.Repo{Name: "repo1", FullName: "org/repo1"})
+
+	assert.Nil(t, bound.Execute(context.Background(), &vfs.OS{Root: clone}))
+	assert.Equal(t, "Added LICENSE", bound.(tasks.Summarizer).Summary())
+	assert.Equal(t, "", task.Summary(), "the messages belong to the bound task")
+	request, _ := ioutil.ReadFile(filepath.Join(clone, "LICENSE"))
+	assert.Contains(t, string(request), `"version":1`)
+	assert.Contains(t, string(request), `"dir":"` + clone + `"`)
+	assert.Contains(t, string(request), `"full_name":"org/repo1"`)
+	assert.Contains(t, string(request), `"owner":"org"`)
+	assert.Contains(t, string(request), `"params":{"holder":"Acme"}`)
+
+	err = bound.Execute(context.Background(), &vfs.OS{Root: clone})
+	assert.Equal(t, "LICENSE already exists", err.Error())
+
+	err = bound.Execute(context.Background(), vfs.NewMemory(nil))
+	assert.Equal(t, "The LICENSE plugin needs a clone of the repo", err.Error())
+}
+
+func TestExecuteError(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "plugins")
+	defer os.RemoveAll(dir)
+	clone, _ := ioutil.TempDir("", "clone")
+	defer os.RemoveAll(clone)
+	plugin, _ := Describe(context.Background(), writePlugin(t, dir, PREFIX + "license", SAMPLE_PLUGIN))
+
+	task := &Task{Plugin: plugin, Params: map[string]string{"holder": "fail"}}
+	err := task.ForRepo(github.Repo{Name: "repo1"}).Execute(context.Background(), &vfs.OS{Root: clone})
+	assert.IsType(t, &tasks.TaskFailure{}, err)
+	assert.Equal(t, "The LICENSE plugin failed: holder rejected", err.Error())
+
+	plugin.Path = writePlugin(t, dir, PREFIX + "crash", "#!/bin/sh\nexit 3\n")
+	err = task.ForRepo(github.Repo{Name: "repo1"}).Execute(context.Background(), &vfs.OS{Root: clone})
+	assert.Equal(t, "The LICENSE plugin failed: exit status 3", err.Error())
+}
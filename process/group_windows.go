@@ -0,0 +1,9 @@
+package process
+
+import (
+	"os/exec"
+)
+
+// inNewGroup keeps the default cancellation on Windows, which only kills the command itself
+func inNewGroup(cmd *exec.Cmd) {
+}
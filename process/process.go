@@ -0,0 +1,19 @@
+package process
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// WAIT_DELAY bounds how long a killed command waits for its children still holding its output
+const WAIT_DELAY = 5 * time.Second
+
+// Command prepares a command killed along with all the processes it started, such as the ones of a shell or of npm,
+// when the context is done
+func Command(ctx context.Context, name string, arg ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, arg...)
+	inNewGroup(cmd)
+	cmd.WaitDelay = WAIT_DELAY
+	return cmd
+}
@@ -0,0 +1,25 @@
+package process
+
+import (
+	"context"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandKillsGroup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100 * time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	// the output is read until the grandchild sleep exits, unless it is killed too
+	_, err := Command(ctx, "sh", "-c", "sleep 10 & sleep 10").Output()
+	assert.NotNil(t, err)
+	assert.True(t, time.Since(start) < 2 * time.Second)
+}
+
+func TestCommandSuccess(t *testing.T) {
+	out, err := Command(context.Background(), "echo", "hello").Output()
+	assert.Nil(t, err)
+	assert.Equal(t, "hello\n", string(out))
+}
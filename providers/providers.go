@@ -0,0 +1,146 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/gitlab"
+	"github.com/transcovo/foreachrepo/bitbucket"
+	"github.com/transcovo/foreachrepo/gitea"
+)
+
+// Provider abstracts the code hosting services foreachrepo can list repos from and open change requests on
+// (pull requests on Github, merge requests on GitLab)
+type Provider interface {
+	ListRepos(scope string) ([]github.Repo, error)
+	CreateChangeRequest(ctx context.Context, repo github.Repo, branch string, title string, options github.PullRequestOptions) (github.PullRequest, []string)
+}
+
+// GithubProvider lists the repos of an organization and opens pull requests
+type GithubProvider struct {
+	Client github.HttpClient
+}
+
+func (P *GithubProvider) ListRepos(organization string) ([]github.Repo, error) {
+	return github.GetReposList(P.Client, organization)
+}
+
+func (P *GithubProvider) CreateChangeRequest(ctx context.Context, repo github.Repo, branch string, title string, options github.PullRequestOptions) (github.PullRequest, []string) {
+	return github.CreatePullRequest(github.WithContext(P.Client, ctx), repo, branch, title, options)
+}
+
+// Fork forks the repo to the account of the authenticated user, for repos it can't push to
+func (P *GithubProvider) Fork(ctx context.Context, repo github.Repo) (github.Repo, error) {
+	return github.ForkRepo(github.WithContext(P.Client, ctx), repo)
+}
+
+// ReadFile reads a file of the default branch of the repo, without cloning it
+func (P *GithubProvider) ReadFile(ctx context.Context, repo github.Repo, path string) ([]byte, error) {
+	return github.GetFile(github.WithContext(P.Client, ctx), repo, path)
+}
+
+// CommitFiles commits the files on a new branch of the repo, without cloning it
+func (P *GithubProvider) CommitFiles(ctx context.Context, repo github.Repo, branch string, message string, author *github.CommitAuthor, files map[string][]byte) error {
+	return github.CommitFiles(github.WithContext(P.Client, ctx), repo, branch, message, author, files)
+}
+
+// GitlabProvider lists the projects of a group and its subgroups and opens merge requests
+type GitlabProvider struct {
+	Client *gitlab.Client
+}
+
+func (P *GitlabProvider) ListRepos(group string) ([]github.Repo, error) {
+	return P.Client.GetGroupProjects(group)
+}
+
+func (P *GitlabProvider) CreateChangeRequest(ctx context.Context, repo github.Repo, branch string, title string, options github.PullRequestOptions) (github.PullRequest, []string) {
+	client := &gitlab.Client{Client: *P.Client.WithContext(ctx)}
+	return client.CreateMergeRequest(repo, branch, title, options)
+}
+
+// BitbucketProvider lists the repos of a Bitbucket Server project and opens pull requests
+type BitbucketProvider struct {
+	Client *bitbucket.Client
+}
+
+func (P *BitbucketProvider) ListRepos(project string) ([]github.Repo, error) {
+	return P.Client.GetProjectRepos(project)
+}
+
+func (P *BitbucketProvider) CreateChangeRequest(ctx context.Context, repo github.Repo, branch string, title string, options github.PullRequestOptions) (github.PullRequest, []string) {
+	client := &bitbucket.Client{Client: *P.Client.WithContext(ctx)}
+	return client.CreatePullRequest(repo, branch, title, options)
+}
+
+// GiteaProvider lists the repos of a Gitea organization and opens pull requests
+type GiteaProvider struct {
+	Client *gitea.Client
+}
+
+func (P *GiteaProvider) ListRepos(organization string) ([]github.Repo, error) {
+	return P.Client.GetOrgRepos(organization)
+}
+
+func (P *GiteaProvider) CreateChangeRequest(ctx context.Context, repo github.Repo, branch string, title string, options github.PullRequestOptions) (github.PullRequest, []string) {
+	client := &gitea.Client{Client: *P.Client.WithContext(ctx)}
+	return client.CreatePullRequest(repo, branch, title, options)
+}
+
+// Registry holds the configured providers by name, Github being registered under the empty name
+type Registry map[string]Provider
+
+// CreateChangeRequest opens the change request on the provider hosting the repo
+func (R Registry) CreateChangeRequest(ctx context.Context, repo github.Repo, branch string, title string, options github.PullRequestOptions) (github.PullRequest, []string) {
+	provider, ok := R[repo.Provider]
+	if !ok {
+		panic(errors.New("No provider configured for " + repo.Provider + " repos"))
+	}
+	return provider.CreateChangeRequest(ctx, repo, branch, title, options)
+}
+
+type forker interface {
+	Fork(ctx context.Context, repo github.Repo) (github.Repo, error)
+}
+
+// Fork forks the repo on the provider hosting it, if that provider supports the fork workflow
+func (R Registry) Fork(ctx context.Context, repo github.Repo) (github.Repo, error) {
+	provider, ok := R[repo.Provider].(forker)
+	if !ok {
+		return github.Repo{}, errors.New("Forking is not supported for " + repo.DisplayName())
+	}
+	return provider.Fork(ctx, repo)
+}
+
+type apiCommitter interface {
+	ReadFile(ctx context.Context, repo github.Repo, path string) ([]byte, error)
+	CommitFiles(ctx context.Context, repo github.Repo, branch string, message string, author *github.CommitAuthor, files map[string][]byte) error
+}
+
+func (R Registry) apiCommitter(repo github.Repo) (apiCommitter, error) {
+	if repo.PullsUrl == "" {
+		return nil, errors.New("Committing through the API is not possible for " + repo.DisplayName() + ", a repo outside of a provider")
+	}
+	provider, ok := R[repo.Provider].(apiCommitter)
+	if !ok {
+		return nil, errors.New("Committing through the API is not supported for " + repo.DisplayName())
+	}
+	return provider, nil
+}
+
+// ReadFile reads a file of the repo on the provider hosting it, if that provider supports API commits
+func (R Registry) ReadFile(ctx context.Context, repo github.Repo, path string) ([]byte, error) {
+	provider, err := R.apiCommitter(repo)
+	if err != nil {
+		return nil, err
+	}
+	return provider.ReadFile(ctx, repo, path)
+}
+
+// CommitFiles commits the files on a new branch on the provider hosting the repo, if it supports API commits
+func (R Registry) CommitFiles(ctx context.Context, repo github.Repo, branch string, message string, author *github.CommitAuthor, files map[string][]byte) error {
+	provider, err := R.apiCommitter(repo)
+	if err != nil {
+		return err
+	}
+	return provider.CommitFiles(ctx, repo, branch, message, author, files)
+}
@@ -0,0 +1,52 @@
+package providers
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/transcovo/foreachrepo/github"
+	"testing"
+)
+
+type TestProvider struct {
+	name  string
+	repos []string
+}
+
+func (P *TestProvider) ListRepos(scope string) ([]github.Repo, error) {
+	return []github.Repo{{Name: scope, Provider: P.name}}, nil
+}
+
+func (P *TestProvider) CreateChangeRequest(ctx context.Context, repo github.Repo, branch string, title string, options github.PullRequestOptions) (github.PullRequest, []string) {
+	P.repos = append(P.repos, repo.Name)
+	return github.PullRequest{Url: P.name + "/" + repo.Name}, nil
+}
+
+func TestRegistryDispatch(t *testing.T) {
+	githubProvider := &TestProvider{name: "github"}
+	gitlabProvider := &TestProvider{name: "gitlab"}
+	registry := Registry{"": githubProvider, "gitlab": gitlabProvider}
+
+	pull, _ := registry.CreateChangeRequest(context.Background(), github.Repo{Name: "repo1"}, "branch", "title", github.PullRequestOptions{})
+	assert.Equal(t, "github/repo1", pull.Url)
+	pull, _ = registry.CreateChangeRequest(context.Background(), github.Repo{Name: "repo2", Provider: "gitlab"}, "branch", "title", github.PullRequestOptions{})
+	assert.Equal(t, "gitlab/repo2", pull.Url)
+
+	assert.Equal(t, []string{"repo1"}, githubProvider.repos)
+	assert.Equal(t, []string{"repo2"}, gitlabProvider.repos)
+}
+
+func TestRegistryUnknownProvider(t *testing.T) {
+	registry := Registry{}
+	assert.Panics(t, func() {
+		registry.CreateChangeRequest(context.Background(), github.Repo{Name: "repo1", Provider: "gitea"}, "branch", "title", github.PullRequestOptions{})
+	})
+}
+
+func TestRegistryApiCommitsUnsupported(t *testing.T) {
+	registry := Registry{"gitlab": &TestProvider{name: "gitlab"}}
+	_, err := registry.ReadFile(context.Background(), github.Repo{FullName: "acme/api", PullsUrl: "https://gitlab.example.com/api/v4/projects/1/merge_requests", Provider: "gitlab"}, "package.json")
+	assert.Equal(t, "Committing through the API is not supported for acme/api", err.Error())
+
+	err = Registry{"": &GithubProvider{}}.CommitFiles(context.Background(), github.Repo{FullName: "example.com/acme/api"}, "branch", "message", nil, map[string][]byte{})
+	assert.Equal(t, "Committing through the API is not possible for example.com/acme/api, a repo outside of a provider", err.Error())
+}
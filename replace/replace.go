@@ -0,0 +1,167 @@
+package replace
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/gitignore"
+	"github.com/transcovo/foreachrepo/tasks"
+	"github.com/transcovo/foreachrepo/vfs"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// VENDOR_DIRS are the directories of third party code, never edited
+var VENDOR_DIRS = []string{"node_modules", "vendor", "bower_components"}
+
+// BINARY_SNIFF_LENGTH is how many bytes are looked at for a NUL byte to tell binary files apart, as git does
+const BINARY_SNIFF_LENGTH = 8000
+
+type NoMatch struct {
+	pattern string
+}
+
+func (N *NoMatch) Error() string {
+	return "No match for " + N.pattern
+}
+
+type InvalidFilesPattern struct {
+	pattern string
+	err     error
+}
+
+func (I *InvalidFilesPattern) Error() string {
+	return "Invalid files pattern " + I.pattern + ": " + I.err.Error()
+}
+
+// Task replaces the matches of a regexp in the files matching gitignore-style patterns. The replacement can refer
+// to the capture groups as $1 or ${name}.
+type Task struct {
+	files       []*regexp.Regexp
+	pattern     *regexp.Regexp
+	replacement []byte
+	// the replacements made in the repo, as "index.js: 2 replacement(s)"
+	replaced    []string
+}
+
+func NewTask(files []string, pattern string, replacement string) (*Task, error) {
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	task := &Task{pattern: compiled, replacement: []byte(replacement)}
+	for _, file := range files {
+		compiledFile, err := gitignore.Compile(file)
+		if err != nil {
+			return nil, &InvalidFilesPattern{file, err}
+		}
+		task.files = append(task.files, compiledFile)
+	}
+	return task, nil
+}
+
+func inVendorDir(name string) bool {
+	segments := strings.Split(name, "/")
+	for _, segment := range segments[:len(segments) - 1] {
+		for _, vendorDir := range VENDOR_DIRS {
+			if segment == vendorDir {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isBinary(content []byte) bool {
+	if len(content) > BINARY_SNIFF_LENGTH {
+		content = content[:BINARY_SNIFF_LENGTH]
+	}
+	return bytes.IndexByte(content, 0) != -1
+}
+
+func (T *Task) selected(name string) bool {
+	for _, file := range T.files {
+		if file.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ForRepo returns a copy of the task counting the replacements of a single repo
+func (T *Task) ForRepo(repo github.Repo) tasks.Task {
+	bound := *T
+	bound.replaced = nil
+	return &bound
+}
+
+// Summary lists the number of replacements by file
+func (T *Task) Summary() string {
+	return strings.Join(T.replaced, ", ")
+}
+
+// Candidates lists the files the task looks into: matching its patterns, neither ignored by git nor vendored
+func (T *Task) Candidates(files vfs.FS) ([]string, error) {
+	names, err := files.List()
+	if err != nil {
+		return nil, err
+	}
+	ignored, err := gitignore.Load(files, names)
+	if err != nil {
+		return nil, err
+	}
+	candidates := []string{}
+	for _, name := range names {
+		if T.selected(name) && !inVendorDir(name) && !ignored.Ignored(name) {
+			candidates = append(candidates, name)
+		}
+	}
+	return candidates, nil
+}
+
+// Replace applies the replacement to a content, returning the new content and the number of replaced matches
+func (T *Task) Replace(content []byte) ([]byte, int) {
+	count := len(T.pattern.FindAllIndex(content, -1))
+	if count == 0 {
+		return content, 0
+	}
+	return T.pattern.ReplaceAll(content, T.replacement), count
+}
+
+func (T *Task) Execute(ctx context.Context, files vfs.FS) error {
+	candidates, err := T.Candidates(files)
+	if err != nil {
+		return err
+	}
+	total := 0
+	for _, name := range candidates {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		content, err := files.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if isBinary(content) {
+			continue
+		}
+		replaced, count := T.Replace(content)
+		if count == 0 || bytes.Equal(replaced, content) {
+			continue
+		}
+		// the mode only applies to new files, existing ones keep theirs
+		if err := files.WriteFile(name, replaced, 0644); err != nil {
+			return err
+		}
+		summary := name + ": " + fmt.Sprint(count) + " replacement(s)"
+		log.Print(summary)
+		T.replaced = append(T.replaced, summary)
+		total += count
+	}
+	if total == 0 {
+		return &NoMatch{T.pattern.String()}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package replace
+
+import (
+	"context"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/vfs"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestReplace(t *testing.T) {
+	task, err := NewTask([]string{"**/*.js"}, `require\('(\w+)'\)`, "require('@acme/$1')")
+	assert.Nil(t, err)
+	replaced, count := task.Replace([]byte("const a = require('a');\nconst b = require('b');\n"))
+	assert.Equal(t, 2, count)
+	assert.Equal(t, "const a = require('@acme/a');\nconst b = require('@acme/b');\n", string(replaced))
+}
+
+func TestExecute(t *testing.T) {
+	files := vfs.NewMemory(map[string][]byte{
+		".gitignore": []byte("dist/\n"),
+		"index.js": []byte("var x = 'old';\n"),
+		"lib/util.js": []byte("old(); old();\n"),
+		"lib/util.ts": []byte("old();\n"),
+		"dist/bundle.js": []byte("old();\n"),
+		"node_modules/dep/index.js": []byte("old();\n"),
+		"vendor/lib.js": []byte("old();\n"),
+		"image.js": []byte("old\x00binary"),
+		"other.js": []byte("nothing to see\n"),
+	})
+	task, err := NewTask([]string{"**/*.js"}, "old", "new")
+	assert.Nil(t, err)
+
+	bound := task.ForRepo(github.Repo{Name: "repo1"}).(*Task)
+	err = bound.Execute(context.Background(), files)
+	assert.Nil(t, err)
+	changed, _ := files.ChangedFiles()
+	assert.Equal(t, []string{"index.js", "lib/util.js"}, changed)
+	content, _ := files.ReadFile("lib/util.js")
+	assert.Equal(t, "new(); new();\n", string(content))
+	assert.Equal(t, "index.js: 1 replacement(s), lib/util.js: 2 replacement(s)", bound.Summary())
+	assert.Equal(t, "", task.Summary(), "the counts are kept by repo")
+}
+
+func TestExecuteNoMatch(t *testing.T) {
+	files := vfs.NewMemory(map[string][]byte{"index.js": []byte("nothing to see\n")})
+	task, _ := NewTask([]string{"*.js"}, "old", "new")
+	err := task.Execute(context.Background(), files)
+	assert.Equal(t, "No match for old", err.Error())
+}
+
+func TestNewTaskInvalidPattern(t *testing.T) {
+	_, err := NewTask([]string{"*.js"}, "(", "new")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,158 @@
+package sources
+
+import (
+	"bufio"
+	"github.com/transcovo/foreachrepo/git"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/providers"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// RepoSource produces the repos a run iterates on
+type RepoSource interface {
+	List() ([]github.Repo, error)
+}
+
+type GithubOrgSource struct {
+	Client       github.HttpClient
+	Organization string
+	Graphql      bool
+}
+
+func (S *GithubOrgSource) List() ([]github.Repo, error) {
+	if S.Graphql {
+		return github.GetReposListGraphql(S.Client, S.Organization)
+	}
+	return github.GetReposList(S.Client, S.Organization)
+}
+
+type GithubUserSource struct {
+	Client github.HttpClient
+	User   string
+}
+
+func (S *GithubUserSource) List() ([]github.Repo, error) {
+	return github.GetUserReposList(S.Client, S.User)
+}
+
+type GithubTeamSource struct {
+	Client       github.HttpClient
+	Organization string
+	Team         string
+}
+
+func (S *GithubTeamSource) List() ([]github.Repo, error) {
+	return github.GetTeamReposList(S.Client, S.Organization, S.Team)
+}
+
+type GithubAuthenticatedUserSource struct {
+	Client github.HttpClient
+}
+
+func (S *GithubAuthenticatedUserSource) List() ([]github.Repo, error) {
+	return github.GetAuthenticatedUserReposList(S.Client)
+}
+
+type GithubSearchSource struct {
+	Client github.HttpClient
+	Query  string
+}
+
+func (S *GithubSearchSource) List() ([]github.Repo, error) {
+	return github.SearchCodeReposList(S.Client, S.Query)
+}
+
+// ProviderSource lists the repos of a scope of a provider, like a GitLab group
+type ProviderSource struct {
+	Provider providers.Provider
+	Scope    string
+}
+
+func (S *ProviderSource) List() ([]github.Repo, error) {
+	return S.Provider.ListRepos(S.Scope)
+}
+
+var gitUrlPattern = regexp.MustCompile(`^(?:[a-z+]+://)?(?:[^@/]+@)?([^:/]+)(?::\d+)?[:/](.+?)(?:\.git)?/?$`)
+
+// repoFromUrl builds a repo without pull request support from any git url, its full name being made of the host and
+// the path, like gitlab.example.com/group/project
+func repoFromUrl(gitUrl string) github.Repo {
+	repo := github.Repo{Name: gitUrl, FullName: gitUrl, GitUrl: gitUrl}
+	if match := gitUrlPattern.FindStringSubmatch(gitUrl); match != nil {
+		repo.FullName = match[1] + "/" + match[2]
+		repo.Name = filepath.Base(match[2])
+	}
+	return repo
+}
+
+// UrlListSource reads git urls from a file, one per line, blank lines and lines starting with # being ignored.
+// The repos are cloned and pushed like Github ones, but no pull request is opened.
+type UrlListSource struct {
+	File string
+}
+
+func (S *UrlListSource) List() ([]github.Repo, error) {
+	file, err := os.Open(S.File)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	repos := []github.Repo{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		repos = append(repos, repoFromUrl(line))
+	}
+	return repos, scanner.Err()
+}
+
+// DirSource iterates on the existing clones found directly in a directory. The tasks are run in place, their
+// changes committed in a new branch and pushed to origin, but no pull request is opened.
+type DirSource struct {
+	Dir string
+}
+
+func (S *DirSource) List() ([]github.Repo, error) {
+	entries, err := ioutil.ReadDir(S.Dir)
+	if err != nil {
+		return nil, err
+	}
+	repos := []github.Repo{}
+	for _, entry := range entries {
+		dir, err := filepath.Abs(filepath.Join(S.Dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+			continue
+		}
+		origin, _ := git.Git(dir).Output("git", "config", "--get", "remote.origin.url")
+		repo := github.Repo{Name: entry.Name(), FullName: dir, LocalDir: dir}
+		if origin = strings.TrimSpace(origin); origin != "" {
+			repo.GitUrl = origin
+		}
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
+
+// ListAll lists the repos of all the sources, without duplicates
+func ListAll(sources []RepoSource) ([]github.Repo, error) {
+	repos := []github.Repo{}
+	for _, source := range sources {
+		sourceRepos, err := source.List()
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, sourceRepos...)
+	}
+	return github.UniqueRepos(repos), nil
+}
@@ -0,0 +1,91 @@
+package sources
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/transcovo/foreachrepo/git"
+	"github.com/transcovo/foreachrepo/github"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func tempDir() string {
+	dir, mkdir_err := ioutil.TempDir("", "")
+	if mkdir_err != nil {
+		panic(mkdir_err)
+	}
+	return dir
+}
+
+func TestRepoFromUrl(t *testing.T) {
+	cases := map[string][]string{
+		"git@gitlab.example.com:group/sub/project.git": {"project", "gitlab.example.com/group/sub/project"},
+		"https://gitlab.example.com/group/project.git": {"project", "gitlab.example.com/group/project"},
+		"ssh://git@git.example.com:2222/tools/cli": {"cli", "git.example.com/tools/cli"},
+		"/srv/git/local.git": {"/srv/git/local.git", "/srv/git/local.git"},
+	}
+	for url, expected := range cases {
+		repo := repoFromUrl(url)
+		assert.Equal(t, expected[0], repo.Name, url)
+		assert.Equal(t, expected[1], repo.FullName, url)
+		assert.Equal(t, url, repo.GitUrl)
+		assert.Equal(t, "", repo.PullsUrl)
+	}
+}
+
+func TestUrlListSource(t *testing.T) {
+	dir := tempDir()
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "urls.txt")
+	ioutil.WriteFile(file, []byte("# our gitlab\ngit@gitlab.example.com:group/project.git\n\n  https://gitea.example.com/tools/cli.git  \n"), 0644)
+
+	repos, err := (&UrlListSource{file}).List()
+	assert.Nil(t, err)
+	assert.Len(t, repos, 2)
+	assert.Equal(t, "gitlab.example.com/group/project", repos[0].FullName)
+	assert.Equal(t, "https://gitea.example.com/tools/cli.git", repos[1].GitUrl)
+
+	_, err = (&UrlListSource{filepath.Join(dir, "missing.txt")}).List()
+	assert.NotNil(t, err)
+}
+
+func TestDirSource(t *testing.T) {
+	dir := tempDir()
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "clone"), 0755)
+	os.Mkdir(filepath.Join(dir, "not-a-clone"), 0755)
+	g := git.Git(filepath.Join(dir, "clone"))
+	g.Exec("git", "init")
+	g.Exec("git", "remote", "add", "origin", "git@gitlab.example.com:group/clone.git")
+
+	repos, err := (&DirSource{dir}).List()
+	assert.Nil(t, err)
+	assert.Len(t, repos, 1)
+	assert.Equal(t, "clone", repos[0].Name)
+	assert.Equal(t, filepath.Join(dir, "clone"), repos[0].LocalDir)
+	assert.Equal(t, "git@gitlab.example.com:group/clone.git", repos[0].GitUrl)
+}
+
+type staticSource struct {
+	repos []github.Repo
+	err   error
+}
+
+func (S *staticSource) List() ([]github.Repo, error) {
+	return S.repos, S.err
+}
+
+func TestListAll(t *testing.T) {
+	repos, err := ListAll([]RepoSource{
+		&staticSource{repos: []github.Repo{{Name: "repo1", FullName: "org/repo1"}, {Name: "repo2", FullName: "org/repo2"}}},
+		&staticSource{repos: []github.Repo{{Name: "repo1", FullName: "org/repo1"}}},
+	})
+	assert.Nil(t, err)
+	assert.Len(t, repos, 2)
+
+	repos, err = ListAll([]RepoSource{&staticSource{err: errors.New("Mock error")}})
+	assert.Nil(t, repos)
+	assert.Equal(t, "Mock error", err.Error())
+}
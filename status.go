@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/tasks"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const STATUS_EXAMPLES = `. Examples:
+
+Follow the pull requests of a campaign, found by branch name:
+
+$> foreachrepo status -org transcovo -campaign freeze-all-deps
+
+Follow the pull requests listed in a saved run report, as JSON:
+
+$> foreachrepo status -report run.json -format json`
+
+// campaignPullUrls finds the campaign pull requests in the saved report if one is given, or by searching the
+// organization for pull requests from the campaign branch otherwise
+func campaignPullUrls(getter github.HttpGetter, organization string, campaign string, reportFile string) ([]string, error) {
+	if reportFile != "" {
+		report, err := tasks.LoadReport(reportFile)
+		if err != nil {
+			return nil, err
+		}
+		return report.PullUrls(), nil
+	}
+	if organization == "DEFAULT" {
+		return nil, errors.New("org flag required when the campaign is a branch name")
+	}
+	return github.FindCampaignPullRequests(getter, organization, campaign)
+}
+
+// campaignStatuses loads the status of every pull request of the campaign, those which can't be loaded are logged
+// and left out
+func campaignStatuses(getter github.HttpGetter, organization string, campaign string, reportFile string) ([]*github.PullRequestStatus, error) {
+	urls, err := campaignPullUrls(getter, organization, campaign, reportFile)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []*github.PullRequestStatus{}
+	for _, url := range urls {
+		status, err := github.GetPullRequestStatus(getter, url)
+		if err != nil {
+			log.Println(url, " -> failed: ", err.Error())
+			continue
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func formatAge(age time.Duration) string {
+	if age >= 24 * time.Hour {
+		return fmt.Sprintf("%dd", int(age.Hours()) / 24)
+	}
+	return fmt.Sprintf("%dh", int(age.Hours()))
+}
+
+func printStatusTable(statuses []*github.PullRequestStatus) {
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tSTATE\tMERGED\tCI\tREVIEW\tAGE\tURL")
+	for _, status := range statuses {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", status.Repo, status.State, status.Merged, status.CiStatus,
+			status.ReviewDecision, formatAge(status.Age(now)), status.Url)
+	}
+	w.Flush()
+}
+
+func statusCommand(args []string) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	organization := flags.String("org", "DEFAULT", "The organization to search the campaign pull requests in")
+	campaign := flags.String("campaign", "DEFAULT", "The campaign branch name")
+	reportFile := flags.String("report", "", "A saved run report listing the campaign pull requests, instead of searching them")
+	format := flags.String("format", "table", "The output format, table or json")
+	flags.Parse(args)
+
+	if *campaign == "DEFAULT" && *reportFile == "" {
+		log.Fatalln("campaign or report flag required", STATUS_EXAMPLES)
+	}
+	if *format != "table" && *format != "json" {
+		log.Fatalln("Unknown format ", *format, STATUS_EXAMPLES)
+	}
+
+	httpInterface := githubHttpInterface()
+
+	statuses, err := campaignStatuses(httpInterface, *organization, *campaign, *reportFile)
+	if err != nil {
+		log.Fatalln("Could not list the campaign pull requests:", err, STATUS_EXAMPLES)
+	}
+
+	if *format == "json" {
+		bytes, _ := json.MarshalIndent(statuses, "", "  ")
+		fmt.Println(string(bytes))
+	} else {
+		printStatusTable(statuses)
+	}
+}
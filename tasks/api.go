@@ -0,0 +1,110 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"github.com/transcovo/foreachrepo/git"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/vfs"
+	"log"
+	"os"
+)
+
+// ApiCommitter reads and commits the files of a repo through the API of its provider, without cloning it
+type ApiCommitter interface {
+	ReadFile(ctx context.Context, repo github.Repo, path string) ([]byte, error)
+	CommitFiles(ctx context.Context, repo github.Repo, branch string, message string, author *github.CommitAuthor, files map[string][]byte) error
+}
+
+// apiCommitMessage adds the trailers of the options to the message, git adding the sign-off itself otherwise. The
+// sign-off needs the author, the identity of the host being unknown to the API.
+func apiCommitMessage(options git.CommitOptions, message string) (string, error) {
+	message = git.CommitMessage(message, options)
+	if options.SignOff {
+		if options.AuthorName == "" || options.AuthorEmail == "" {
+			return "", errors.New("Signing off through the API needs the name and email of the author")
+		}
+		if len(options.CoAuthors) == 0 {
+			message += "\n"
+		}
+		message += "\nSigned-off-by: " + options.AuthorName + " <" + options.AuthorEmail + ">"
+	}
+	return message, nil
+}
+
+// executeViaApi runs a file task on the files of the repo read through the API, and commits its changes the same way
+func executeViaApi(ctx context.Context, creator ChangeRequestCreator, repo github.Repo, task Task, options Options) (result Result) {
+	result = Result{Repo: repo.DisplayName()}
+	fail := func(err error) Result {
+		log.Println(repo.Name, " -> failed: ", err.Error())
+		result.Status, result.Message, result.Failure = STATUS_FAILED, err.Error(), failureOf(ctx, err)
+		return result
+	}
+
+	committer, ok := creator.(ApiCommitter)
+	if !ok {
+		return fail(errors.New("Committing through the API is not supported"))
+	}
+	fileTask, ok := task.(FileTask)
+	if !ok {
+		return fail(errors.New("The task needs a clone of the repo, it can't run through the API"))
+	}
+
+	files := vfs.NewMemory(nil)
+	files.Load = func(name string) ([]byte, error) {
+		return committer.ReadFile(ctx, repo, name)
+	}
+	for _, name := range fileTask.Files() {
+		if _, err := files.ReadFile(name); err != nil && !os.IsNotExist(err) {
+			return fail(err)
+		}
+	}
+
+	err := task.Execute(ctx, files)
+	if err != nil {
+		if failure := taskFailureOf(ctx, err); failure != "" {
+			log.Println(repo.Name, " -> failed: ", err.Error())
+			result.Status, result.Message, result.Failure = STATUS_FAILED, err.Error(), failure
+			return
+		}
+		log.Println(repo.Name, " -> skip: ", err.Error())
+		result.Status, result.Message = STATUS_SKIPPED, err.Error()
+		return
+	}
+	changes := files.Changes()
+	if len(changes) == 0 {
+		log.Println(repo.Name, " -> skip: no changes")
+		result.Status, result.Message = STATUS_SKIPPED, "No changes"
+		return
+	}
+
+	pullRequest := options.PullRequest
+	if options.CodeOwners {
+		pullRequest, err = withCodeOwnersReviewers(files, files, pullRequest, options)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	var author *github.CommitAuthor
+	if options.Commit.AuthorName != "" && options.Commit.AuthorEmail != "" {
+		author = &github.CommitAuthor{Name: options.Commit.AuthorName, Email: options.Commit.AuthorEmail}
+	}
+	message, err := apiCommitMessage(options.Commit, options.CommitMessage)
+	if err != nil {
+		return fail(err)
+	}
+	err = committer.CommitFiles(ctx, repo, options.BranchName, message, author, changes)
+	if err != nil {
+		return fail(err)
+	}
+
+	pull, warnings := creator.CreateChangeRequest(ctx, repo, options.BranchName, options.CommitMessage, pullRequest)
+	for _, warning := range warnings {
+		log.Println(repo.Name, " -> warning: ", warning)
+	}
+	log.Println(repo.Name, " -> done! (", pull.Url, ")")
+	result.Status, result.Url, result.PullUrl, result.Warnings = STATUS_DONE, pull.Url, pull.ApiUrl, warnings
+	result.Message = summaryOf(task)
+	return
+}
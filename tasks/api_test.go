@@ -0,0 +1,128 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/transcovo/foreachrepo/git"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/vfs"
+	"os"
+	"testing"
+)
+
+type TestApiCommitter struct {
+	files     map[string]string
+	reads     []string
+	message   string
+	author    *github.CommitAuthor
+	committed map[string][]byte
+	pull      github.PullRequestOptions
+}
+
+func (T *TestApiCommitter) ReadFile(ctx context.Context, repo github.Repo, path string) ([]byte, error) {
+	T.reads = append(T.reads, path)
+	content, ok := T.files[path]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	return []byte(content), nil
+}
+
+func (T *TestApiCommitter) CommitFiles(ctx context.Context, repo github.Repo, branch string, message string, author *github.CommitAuthor, files map[string][]byte) error {
+	T.message, T.author, T.committed = message, author, files
+	return nil
+}
+
+func (T *TestApiCommitter) CreateChangeRequest(ctx context.Context, repo github.Repo, branch string, title string, options github.PullRequestOptions) (github.PullRequest, []string) {
+	T.pull = options
+	return github.PullRequest{Url: "https://github.com/org/repo1/pull/1"}, nil
+}
+
+// TestFileTask uppercases the name of the package.json
+type TestFileTask struct{}
+
+func (T TestFileTask) Files() []string {
+	return []string{"package.json"}
+}
+
+func (T TestFileTask) Execute(ctx context.Context, files vfs.FS) error {
+	content, err := files.ReadFile("package.json")
+	if err != nil {
+		return err
+	}
+	if string(content) == `{"name": "REPO1"}` {
+		return nil
+	}
+	return files.WriteFile("package.json", []byte(`{"name": "REPO1"}`), 0644)
+}
+
+type TestDirTask struct{}
+
+func (T TestDirTask) Execute(ctx context.Context, files vfs.FS) error {
+	return errors.New("should not run")
+}
+
+func TestExecuteViaApi(t *testing.T) {
+	committer := &TestApiCommitter{files: map[string]string{
+		"package.json": `{"name": "repo1"}`,
+		"CODEOWNERS": "package.json @org/frontend\n",
+	}}
+	result := ExecuteTask(context.Background(), committer, github.Repo{Name: "repo1"}, TestFileTask{}, Options{
+		BranchName: "upper-name",
+		CommitMessage: "Uppercase the name",
+		CodeOwners: true,
+		ApiOnly: true,
+		Commit: git.CommitOptions{AuthorName: "Bot", AuthorEmail: "bot@example.com", SignOff: true},
+	})
+
+	assert.Equal(t, STATUS_DONE, result.Status)
+	assert.Equal(t, "https://github.com/org/repo1/pull/1", result.Url)
+	assert.Equal(t, map[string][]byte{"package.json": []byte(`{"name": "REPO1"}`)}, committer.committed)
+	assert.Equal(t, "Uppercase the name\n\nSigned-off-by: Bot <bot@example.com>", committer.message)
+	assert.Equal(t, &github.CommitAuthor{Name: "Bot", Email: "bot@example.com"}, committer.author)
+	assert.Equal(t, []string{"frontend"}, committer.pull.TeamReviewers)
+	assert.Equal(t, []string{"package.json", ".github/CODEOWNERS", "CODEOWNERS"}, committer.reads)
+}
+
+func TestExecuteViaApiNoChanges(t *testing.T) {
+	committer := &TestApiCommitter{files: map[string]string{"package.json": `{"name": "REPO1"}`}}
+	result := ExecuteTask(context.Background(), committer, github.Repo{Name: "repo1"}, TestFileTask{}, Options{ApiOnly: true})
+	assert.Equal(t, STATUS_SKIPPED, result.Status)
+	assert.Nil(t, committer.committed)
+}
+
+func TestExecuteViaApiNeedsFileTask(t *testing.T) {
+	committer := &TestApiCommitter{}
+	result := ExecuteTask(context.Background(), committer, github.Repo{Name: "repo1"}, TestDirTask{}, Options{ApiOnly: true})
+	assert.Equal(t, STATUS_FAILED, result.Status)
+	assert.Equal(t, "The task needs a clone of the repo, it can't run through the API", result.Message)
+}
+
+// TestFailingFileTask finds a malformed package.json
+type TestFailingFileTask struct {
+	TestFileTask
+}
+
+func (T TestFailingFileTask) Execute(ctx context.Context, files vfs.FS) error {
+	return &TaskFailure{errors.New("Invalid package.json")}
+}
+
+func TestExecuteViaApiTaskFailure(t *testing.T) {
+	committer := &TestApiCommitter{files: map[string]string{"package.json": `{`}}
+	result := ExecuteTask(context.Background(), committer, github.Repo{Name: "repo1"}, TestFailingFileTask{}, Options{ApiOnly: true})
+	assert.Equal(t, STATUS_FAILED, result.Status)
+	assert.Equal(t, FAILURE_TASK, result.Failure)
+	assert.Equal(t, "Invalid package.json", result.Message)
+}
+
+func TestExecuteViaApiSignOffWithoutAuthor(t *testing.T) {
+	committer := &TestApiCommitter{files: map[string]string{"package.json": `{"name": "repo1"}`}}
+	result := ExecuteTask(context.Background(), committer, github.Repo{Name: "repo1"}, TestFileTask{}, Options{
+		ApiOnly: true,
+		Commit: git.CommitOptions{SignOff: true},
+	})
+	assert.Equal(t, STATUS_FAILED, result.Status)
+	assert.Equal(t, "Signing off through the API needs the name and email of the author", result.Message)
+	assert.Nil(t, committer.committed)
+}
@@ -0,0 +1,109 @@
+package tasks
+
+import (
+	"encoding/json"
+	"github.com/transcovo/foreachrepo/github"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Report is the record of a run, saved so that its pull requests can be followed later on
+type Report struct {
+	Campaign     string
+	Organization string
+	Results      []Result
+	// the Github API quota left at the time the report was saved
+	RateLimit    github.RateLimit
+}
+
+// Save writes the report to a temporary file renamed over path once synced, so that a crash at any point leaves
+// either the previous or the new report, never a truncated one
+func (R *Report) Save(path string) error {
+	bytes, err := json.MarshalIndent(R, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path) + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(bytes)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Record stores the result of a repo, replacing the one of a previous run if any
+func (R *Report) Record(result Result) {
+	for i, previous := range R.Results {
+		if previous.Repo == result.Repo {
+			R.Results[i] = result
+			return
+		}
+	}
+	R.Results = append(R.Results, result)
+}
+
+// Outcome returns the result recorded for a repo
+func (R *Report) Outcome(repo string) (Result, bool) {
+	for _, result := range R.Results {
+		if result.Repo == repo {
+			return result, true
+		}
+	}
+	return Result{}, false
+}
+
+// IsFinished tells whether a resumed run can skip the repo, failures being retried
+func (R *Report) IsFinished(repo string) bool {
+	result, ok := R.Outcome(repo)
+	return ok && (result.Status == STATUS_DONE || result.Status == STATUS_SKIPPED)
+}
+
+func LoadReport(path string) (*Report, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{}
+	err = json.Unmarshal(bytes, report)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// FailuresByKind groups the failed repos by kind of failure
+func (R *Report) FailuresByKind() map[string][]string {
+	failures := map[string][]string{}
+	for _, result := range R.Results {
+		if result.Status == STATUS_FAILED {
+			failures[result.Failure] = append(failures[result.Failure], result.Repo)
+		}
+	}
+	return failures
+}
+
+// PullUrls lists the API urls of the pull requests opened during the run
+func (R *Report) PullUrls() []string {
+	urls := []string{}
+	for _, result := range R.Results {
+		if result.PullUrl != "" {
+			urls = append(urls, result.PullUrl)
+		}
+	}
+	return urls
+}
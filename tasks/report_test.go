@@ -0,0 +1,91 @@
+package tasks
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func TestReportSaveAndLoad(t *testing.T) {
+	dir, mkdir_err := ioutil.TempDir("", "")
+	if mkdir_err != nil {
+		panic(mkdir_err)
+	}
+	defer os.RemoveAll(dir)
+
+	report := &Report{
+		Campaign: "freeze-all-deps",
+		Organization: "org",
+		Results: []Result{
+			{Repo: "repo1", Status: STATUS_DONE, Url: "https://github.com/org/repo1/pull/1", PullUrl: "https://api.github.com/repos/org/repo1/pulls/1"},
+			{Repo: "repo2", Status: STATUS_SKIPPED, Message: "No package.json found"},
+		},
+	}
+	path := filepath.Join(dir, "report.json")
+	assert.Nil(t, report.Save(path))
+
+	loaded, err := LoadReport(path)
+	assert.Nil(t, err)
+	assert.Equal(t, report, loaded)
+	assert.Equal(t, []string{"https://api.github.com/repos/org/repo1/pulls/1"}, loaded.PullUrls())
+}
+
+func TestLoadReportMissing(t *testing.T) {
+	report, err := LoadReport("/does/not/exist.json")
+	assert.Nil(t, report)
+	assert.NotNil(t, err)
+}
+
+func TestReportRecord(t *testing.T) {
+	report := &Report{}
+	report.Record(Result{Repo: "repo1", Status: STATUS_FAILED, Message: "Could not push"})
+	report.Record(Result{Repo: "repo2", Status: STATUS_SKIPPED})
+	report.Record(Result{Repo: "repo3", Status: STATUS_DONE})
+	assert.False(t, report.IsFinished("repo1"))
+	assert.True(t, report.IsFinished("repo2"))
+	assert.True(t, report.IsFinished("repo3"))
+	assert.False(t, report.IsFinished("repo4"))
+
+	report.Record(Result{Repo: "repo1", Status: STATUS_DONE})
+	assert.Len(t, report.Results, 3)
+	assert.True(t, report.IsFinished("repo1"))
+	result, ok := report.Outcome("repo1")
+	assert.True(t, ok)
+	assert.Equal(t, STATUS_DONE, result.Status)
+}
+
+func TestReportSaveReplaces(t *testing.T) {
+	dir, mkdir_err := ioutil.TempDir("", "")
+	if mkdir_err != nil {
+		panic(mkdir_err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	report := &Report{Campaign: "freeze-all-deps"}
+	report.Record(Result{Repo: "repo1", Status: STATUS_FAILED})
+	assert.Nil(t, report.Save(path))
+	report.Record(Result{Repo: "repo1", Status: STATUS_DONE})
+	assert.Nil(t, report.Save(path))
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 1, "temporary files must not be left behind")
+	loaded, err := LoadReport(path)
+	assert.Nil(t, err)
+	assert.True(t, loaded.IsFinished("repo1"))
+}
+
+func TestReportFailuresByKind(t *testing.T) {
+	report := &Report{Results: []Result{
+		{Repo: "repo1", Status: STATUS_FAILED, Failure: "auth denied"},
+		{Repo: "repo2", Status: STATUS_DONE},
+		{Repo: "repo3", Status: STATUS_FAILED, Failure: "protected branch"},
+		{Repo: "repo4", Status: STATUS_FAILED, Failure: "auth denied"},
+	}}
+	assert.Equal(t, map[string][]string{
+		"auth denied": {"repo1", "repo4"},
+		"protected branch": {"repo3"},
+	}, report.FailuresByKind())
+}
@@ -1,51 +1,358 @@
 package tasks
 
 import (
+	"context"
+	"errors"
 	"github.com/transcovo/foreachrepo/github"
 	"github.com/transcovo/foreachrepo/git"
+	"github.com/transcovo/foreachrepo/codeowners"
+	"github.com/transcovo/foreachrepo/vfs"
 	"log"
 	"os"
 )
 
+// Task changes the files of a repo. Its commands must be stopped when the context is done, on timeouts and
+// interruptions.
 type Task interface {
-	Execute(dir string) error
+	Execute(ctx context.Context, files vfs.FS) error
 }
 
-func ExecuteTask(httpInterface *github.AuthHttpInterface, repo github.Repo, task Task, branchName string, commitMessage string) string {
+// FileTask is a task only reading and writing the files it declares, which can run without cloning the repo
+type FileTask interface {
+	Task
+	Files() []string
+}
+
+// RepoTask is a task depending on the repo it runs on, bound to each repo before running
+type RepoTask interface {
+	Task
+	ForRepo(repo github.Repo) Task
+}
+
+// Summarizer is a task telling what it changed in the repo, reported as the message of the result
+type Summarizer interface {
+	Task
+	Summary() string
+}
+
+// summaryOf returns the summary of the changes of a task, empty when it doesn't tell
+func summaryOf(task Task) string {
+	if summarizer, ok := task.(Summarizer); ok {
+		return summarizer.Summary()
+	}
+	return ""
+}
+
+// FAILURE_TASK is the kind of failure of the repos a task failed on
+const FAILURE_TASK = "task failed"
+
+// TaskFailure wraps the errors of a task for which the repo must be reported as failed rather than skipped, such as
+// a malformed file
+type TaskFailure struct {
+	Err error
+}
+
+func (T *TaskFailure) Error() string {
+	return T.Err.Error()
+}
+
+// taskFailureOf classifies the failure of a task, empty for the errors skipping the repo
+func taskFailureOf(ctx context.Context, err error) string {
+	if failure := git.ContextFailure(ctx); failure != "" {
+		return failure
+	}
+	if _, ok := err.(*TaskFailure); ok {
+		return FAILURE_TASK
+	}
+	return ""
+}
+
+// FAILURE_DIRTY is the kind of failure of the existing clones with uncommitted changes, which aren't worked on
+const FAILURE_DIRTY = "uncommitted changes"
+
+type DirtyWorktree struct {
+	dir string
+}
+
+func (D *DirtyWorktree) Error() string {
+	return D.dir + " has uncommitted changes, commit or stash them first"
+}
+
+// Forker forks a repo, and returns the fork
+type Forker interface {
+	Fork(ctx context.Context, repo github.Repo) (github.Repo, error)
+}
+
+// ChangeRequestCreator opens the pull request, or its equivalent, of a pushed branch
+type ChangeRequestCreator interface {
+	CreateChangeRequest(ctx context.Context, repo github.Repo, branch string, title string, options github.PullRequestOptions) (github.PullRequest, []string)
+}
+
+// Options holds the settings shared by every repo of a run
+type Options struct {
+	BranchName    string
+	CommitMessage string
+	PullRequest   github.PullRequestOptions
+	// request reviews from the owners of the changed files, or from the fallback reviewers without CODEOWNERS
+	CodeOwners        bool
+	FallbackReviewers []string
+	// push to a fork of the repo and open the pull request from there, for repos we can't push to
+	Fork              bool
+	Clone             git.CloneOptions
+	// clone and push over HTTPS instead of SSH, with the credentials of the host of the repo, by git.UrlOrigin
+	Https             bool
+	Credentials       map[string]*git.HttpsCredentials
+	// commit the changes with the provider API instead of cloning the repo, for file tasks
+	ApiOnly           bool
+	Commit            git.CommitOptions
+	// a shell command run after the task, such as the repo's tests, or VERIFY_AUTO to detect it
+	Verify            string
+	// push the repos failing verification anyway, their pull requests being opened as drafts
+	PushUnverified    bool
+}
+
+const (
+	STATUS_DONE = "done"
+	STATUS_SKIPPED = "skipped"
+	STATUS_FAILED = "failed"
+)
+
+// Result is the outcome of a task on a single repo
+type Result struct {
+	Repo     string
+	Status   string
+	Url      string
+	PullUrl  string
+	// why the repo was skipped or failed, or what the task changed in it when it tells
+	Message  string
+	// the kind of failure, one of the git FAILURE_ constants, for failed repos
+	Failure  string
+	Warnings []string
+}
+
+func appendMissing(values []string, additions []string) []string {
+	result := append([]string{}, values...)
+	for _, addition := range additions {
+		found := false
+		for _, value := range result {
+			found = found || value == addition
+		}
+		if !found {
+			result = append(result, addition)
+		}
+	}
+	return result
+}
+
+type changeLister interface {
+	ChangedFiles() ([]string, error)
+}
+
+// withCodeOwnersReviewers adds the owners of the changed files to the pull request reviewers
+func withCodeOwnersReviewers(g changeLister, files vfs.FS, pullRequest github.PullRequestOptions, options Options) (github.PullRequestOptions, error) {
+	owners := options.FallbackReviewers
+
+	codeOwners, err := codeowners.Find(files)
+	if err != nil {
+		return pullRequest, err
+	}
+	if codeOwners != nil {
+		files, err := g.ChangedFiles()
+		if err != nil {
+			return pullRequest, err
+		}
+		owners = codeOwners.OwnersOfAll(files)
+	}
+
+	users, teams := codeowners.SplitOwners(owners)
+	pullRequest.Reviewers = appendMissing(pullRequest.Reviewers, users)
+	pullRequest.TeamReviewers = appendMissing(pullRequest.TeamReviewers, teams)
+	return pullRequest, nil
+}
+
+type remoteAdder interface {
+	AddRemote(name string, url string) error
+}
+
+// addForkRemote forks the repo and adds the fork as a remote, returning the owner of the fork
+func addForkRemote(ctx context.Context, g remoteAdder, creator ChangeRequestCreator, repo github.Repo, remote string, options Options) (string, error) {
+	forker, ok := creator.(Forker)
+	if !ok {
+		return "", errors.New("Forking is not supported")
+	}
+	fork, err := forker.Fork(ctx, repo)
+	if err != nil {
+		return "", err
+	}
+	return fork.Owner(), g.AddRemote(remote, gitUrl(fork, options))
+}
+
+type stager interface {
+	Stage() error
+	DiscardUnstaged() error
+}
+
+// verify runs the verification command of the options, if any. The changes of the task are staged first and
+// whatever the command leaves behind, such as build outputs, is discarded, so that only the task's changes are
+// committed.
+func verify(ctx context.Context, g stager, dir string, options Options) error {
+	if options.Verify == "" {
+		return nil
+	}
+	if err := g.Stage(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := g.DiscardUnstaged(); err != nil {
+			log.Println("Could not discard the outputs of the verification in ", dir, ": ", err.Error())
+		}
+	}()
+	command := VerifyCommand(dir, options.Verify)
+	if command == "" {
+		log.Println("No verification command found in ", dir)
+		return nil
+	}
+	log.Println("Verifying with ", command)
+	return Verify(ctx, dir, command)
+}
+
+// gitUrl returns the url to clone or push the repo with, the SSH one unless HTTPS is asked for and known
+func gitUrl(repo github.Repo, options Options) string {
+	if options.Https && repo.HttpsUrl != "" {
+		return repo.HttpsUrl
+	}
+	return repo.GitUrl
+}
+
+// failureOf classifies a failure, timeouts and interruptions first since they make any command or call fail
+func failureOf(ctx context.Context, err error) string {
+	if failure := git.ContextFailure(ctx); failure != "" {
+		return failure
+	}
+	return git.Classify(err)
+}
+
+func ExecuteTask(ctx context.Context, creator ChangeRequestCreator, repo github.Repo, task Task, options Options) (result Result) {
+	result = Result{Repo: repo.DisplayName()}
 	defer func() {
 		if r := recover(); r != nil {
 			err, ok := r.(error)
 			if ok {
 				log.Println(repo.Name, " -> failed: ", err.Error())
+				result.Message = err.Error()
 			} else {
 				log.Println(repo.Name, " -> failed: unknown error")
+				result.Message = "unknown error"
 			}
+			result.Status, result.Failure = STATUS_FAILED, failureOf(ctx, nil)
 		}
 	}()
 
-	g := git.Git("")
-	dir, err := g.Clone(repo.GitUrl)
-	if err != nil {
-		log.Println(repo.Name, " -> failed: ", err.Error())
-		return ""
+	if repoTask, ok := task.(RepoTask); ok {
+		task = repoTask.ForRepo(repo)
+	}
+	if options.ApiOnly {
+		return executeViaApi(ctx, creator, repo, task, options)
+	}
+
+	var err error
+	dir := repo.LocalDir
+	g := git.GitContext(ctx, dir)
+	if options.Https {
+		g.SetCredentials(options.Credentials[git.UrlOrigin(gitUrl(repo, options))])
+	}
+	if dir == "" {
+		dir, err = g.CloneWith(gitUrl(repo, options), options.Clone)
+		if err != nil {
+			log.Println(repo.Name, " -> failed: ", err.Error())
+			result.Status, result.Message, result.Failure = STATUS_FAILED, err.Error(), failureOf(ctx, err)
+			return
+		}
+		defer os.RemoveAll(dir)
+	} else {
+		// the existing clones are worked on in place: their changes would be committed along with the task's
+		changed, err := g.ChangedFiles()
+		if err == nil && len(changed) > 0 {
+			err = &DirtyWorktree{dir}
+		}
+		var original string
+		if err == nil {
+			original, err = g.CurrentRef()
+		}
+		if err != nil {
+			log.Println(repo.Name, " -> failed: ", err.Error())
+			result.Status, result.Message, result.Failure = STATUS_FAILED, err.Error(), failureOf(ctx, err)
+			if _, ok := err.(*DirtyWorktree); ok {
+				result.Failure = FAILURE_DIRTY
+			}
+			return
+		}
+		// back to where the clone was, even once the context is done
+		defer func() {
+			if err := git.Git(dir).Restore(original); err != nil {
+				log.Println(repo.Name, " -> could not check ", original, " out again: ", err.Error())
+			}
+		}()
 	}
-	defer os.RemoveAll(dir)
 
-	err = task.Execute(dir)
+	files := &vfs.OS{Root: dir}
+	err = task.Execute(ctx, files)
 
 	if err == nil {
-		err = g.CommitAndPushInNewBranch(branchName, commitMessage)
+		pullRequest := options.PullRequest
+		var warnings []string
+		if verifyErr := verify(ctx, g, dir, options); verifyErr != nil {
+			if !options.PushUnverified {
+				log.Println(repo.Name, " -> failed: ", verifyErr.Error())
+				result.Status, result.Message, result.Failure = STATUS_FAILED, verifyErr.Error(), FAILURE_VERIFICATION
+				if failure := git.ContextFailure(ctx); failure != "" {
+					result.Failure = failure
+				}
+				return
+			}
+			pullRequest.Draft = true
+			warnings = append(warnings, verifyErr.Error() + ", opened as a draft")
+		}
+		if options.CodeOwners {
+			pullRequest, err = withCodeOwnersReviewers(g, files, pullRequest, options)
+		}
+		remote := "origin"
+		if err == nil && options.Fork {
+			remote = "fork"
+			pullRequest.HeadOwner, err = addForkRemote(ctx, g, creator, repo, remote, options)
+		}
 		if err == nil {
-			url := github.CreatePullRequest(httpInterface, repo, branchName, commitMessage)
+			err = g.CommitAndPushInNewBranchTo(remote, options.BranchName, options.CommitMessage, options.Commit)
+		}
+		if err == nil && repo.PullsUrl == "" {
+			log.Println(repo.Name, " -> done! (pushed ", options.BranchName, ", no pull request for a repo outside of a provider)")
+			result.Status, result.Message, result.Warnings = STATUS_DONE, summaryOf(task), warnings
+			return
+		}
+		if err == nil {
+			pull, creationWarnings := creator.CreateChangeRequest(ctx, repo, options.BranchName, options.CommitMessage, pullRequest)
+			warnings = append(warnings, creationWarnings...)
+			for _, warning := range warnings {
+				log.Println(repo.Name, " -> warning: ", warning)
+			}
 
-			log.Println(repo.Name, " -> done! (", url, ")")
-			return url
+			log.Println(repo.Name, " -> done! (", pull.Url, ")")
+			result.Status, result.Url, result.PullUrl, result.Warnings = STATUS_DONE, pull.Url, pull.ApiUrl, warnings
+			result.Message = summaryOf(task)
+			return
 		}
 
 		log.Println(repo.Name, " -> failed: ", err.Error())
-		return ""
+		result.Status, result.Message, result.Failure = STATUS_FAILED, err.Error(), failureOf(ctx, err)
+		return
 	}
 
+	if failure := taskFailureOf(ctx, err); failure != "" {
+		log.Println(repo.Name, " -> failed: ", failure, ", ", err.Error())
+		result.Status, result.Message, result.Failure = STATUS_FAILED, err.Error(), failure
+		return
+	}
 	log.Println(repo.Name, " -> skip: ", err.Error())
-	return ""
+	result.Status, result.Message = STATUS_SKIPPED, err.Error()
+	return
 }
@@ -0,0 +1,125 @@
+package tasks
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/transcovo/foreachrepo/git"
+	"github.com/transcovo/foreachrepo/github"
+	"github.com/transcovo/foreachrepo/vfs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWriteTask writes a file
+type TestWriteTask struct{}
+
+func (T TestWriteTask) Execute(ctx context.Context, files vfs.FS) error {
+	return files.WriteFile("task.txt", []byte("done"), 0644)
+}
+
+// makeClone creates a clone with a pushed master branch, checked out on a-branch
+func makeClone(t *testing.T) (string, string) {
+	for _, name := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(name, "Test")
+	}
+	for _, name := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(name, "test@example.com")
+	}
+	dir, _ := ioutil.TempDir("", "clone")
+	origin, _ := ioutil.TempDir("", "origin")
+	g := git.Git(dir)
+	g.Exec("git", "init", "--quiet")
+	g.Exec("git", "init", "--quiet", "--bare", origin)
+	g.Exec("git", "remote", "add", "origin", origin)
+	ioutil.WriteFile(filepath.Join(dir, "file1.txt"), []byte("1"), 0644)
+	g.Exec("git", "add", ".")
+	g.Exec("git", "commit", "--quiet", "-m", "Initial commit")
+	g.Exec("git", "checkout", "--quiet", "-b", "a-branch")
+	return dir, origin
+}
+
+func TestExecuteTaskInPlace(t *testing.T) {
+	dir, origin := makeClone(t)
+	defer os.RemoveAll(dir)
+	defer os.RemoveAll(origin)
+
+	result := ExecuteTask(context.Background(), nil, github.Repo{Name: "repo1", LocalDir: dir}, TestWriteTask{}, Options{
+		BranchName: "campaign",
+		CommitMessage: "Run the task",
+	})
+	assert.Equal(t, STATUS_DONE, result.Status)
+	ref, _ := git.Git(dir).CurrentRef()
+	assert.Equal(t, "a-branch", ref)
+	files, _ := git.Git(dir).ChangedFiles()
+	assert.Empty(t, files)
+	pushed, _ := git.Git(origin).Output("git", "show", "campaign:task.txt")
+	assert.Equal(t, "done", pushed)
+}
+
+func TestExecuteTaskInPlaceDirty(t *testing.T) {
+	dir, origin := makeClone(t)
+	defer os.RemoveAll(dir)
+	defer os.RemoveAll(origin)
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("work in progress"), 0644)
+
+	result := ExecuteTask(context.Background(), nil, github.Repo{Name: "repo1", LocalDir: dir}, TestWriteTask{}, Options{
+		BranchName: "campaign",
+		CommitMessage: "Run the task",
+	})
+	assert.Equal(t, STATUS_FAILED, result.Status)
+	assert.Equal(t, FAILURE_DIRTY, result.Failure)
+	_, err := os.Stat(filepath.Join(dir, "task.txt"))
+	assert.True(t, os.IsNotExist(err))
+	content, _ := ioutil.ReadFile(filepath.Join(dir, "notes.txt"))
+	assert.Equal(t, "work in progress", string(content))
+}
+
+func TestExecuteTaskPushUnverifiedCodeOwners(t *testing.T) {
+	dir, origin := makeClone(t)
+	defer os.RemoveAll(dir)
+	defer os.RemoveAll(origin)
+	g := git.Git(dir)
+	ioutil.WriteFile(filepath.Join(dir, "CODEOWNERS"), []byte("file1.txt @bob\ntask.txt @alice\n"), 0644)
+	g.Exec("git", "add", ".")
+	g.Exec("git", "commit", "--quiet", "-m", "Add the code owners")
+
+	creator := &TestApiCommitter{}
+	result := ExecuteTask(context.Background(), creator, github.Repo{Name: "repo1", LocalDir: dir, PullsUrl: "https://api.github.com/repos/org/repo1/pulls"}, TestWriteTask{}, Options{
+		BranchName: "campaign",
+		CommitMessage: "Run the task",
+		PullRequest: github.PullRequestOptions{Labels: []string{"chore"}},
+		Verify: "echo built > build.log; echo 1 > file1.txt; exit 1",
+		PushUnverified: true,
+		CodeOwners: true,
+	})
+	assert.Equal(t, STATUS_DONE, result.Status)
+	assert.True(t, creator.pull.Draft)
+	assert.Equal(t, []string{"chore"}, creator.pull.Labels)
+	assert.Equal(t, []string{"alice"}, creator.pull.Reviewers)
+	pushed, _ := git.Git(origin).Output("git", "ls-tree", "--name-only", "campaign")
+	assert.Equal(t, "CODEOWNERS\nfile1.txt\ntask.txt\n", pushed)
+}
+
+// TestSummarizingTask writes a file and tells so
+type TestSummarizingTask struct {
+	TestWriteTask
+}
+
+func (T TestSummarizingTask) Summary() string {
+	return "task.txt written"
+}
+
+func TestExecuteTaskSummary(t *testing.T) {
+	dir, origin := makeClone(t)
+	defer os.RemoveAll(dir)
+	defer os.RemoveAll(origin)
+
+	result := ExecuteTask(context.Background(), nil, github.Repo{Name: "repo1", LocalDir: dir}, TestSummarizingTask{}, Options{
+		BranchName: "campaign",
+		CommitMessage: "Run the task",
+	})
+	assert.Equal(t, STATUS_DONE, result.Status)
+	assert.Equal(t, "task.txt written", result.Message)
+}
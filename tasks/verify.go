@@ -0,0 +1,64 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/transcovo/foreachrepo/git"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// VERIFY_AUTO detects the verification command from the repo's package.json or Makefile
+const VERIFY_AUTO = "auto"
+
+const FAILURE_VERIFICATION = "verification failed"
+
+// the test script npm init writes, which always fails
+const NPM_DEFAULT_TEST = `echo "Error: no test specified" && exit 1`
+
+var makeTestTarget = regexp.MustCompile(`(?m)^test\s*:`)
+
+type VerificationError struct {
+	Command string
+	Cause   error
+}
+
+func (V *VerificationError) Error() string {
+	return "Verification with " + V.Command + " failed: " + V.Cause.Error()
+}
+
+type packageScripts struct {
+	Scripts map[string]string
+}
+
+// VerifyCommand returns the command verifying the repo in dir, detected when command is VERIFY_AUTO. It is empty
+// when there's nothing to run.
+func VerifyCommand(dir string, command string) string {
+	if command != VERIFY_AUTO {
+		return command
+	}
+	if bytes, err := ioutil.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+		pkg := &packageScripts{}
+		if json.Unmarshal(bytes, pkg) == nil {
+			test := strings.TrimSpace(pkg.Scripts["test"])
+			if test != "" && test != NPM_DEFAULT_TEST {
+				return "npm test"
+			}
+		}
+	}
+	if bytes, err := ioutil.ReadFile(filepath.Join(dir, "Makefile")); err == nil && makeTestTarget.Match(bytes) {
+		return "make test"
+	}
+	return ""
+}
+
+// Verify runs the verification command with a shell in dir
+func Verify(ctx context.Context, dir string, command string) error {
+	err := git.GitContext(ctx, dir).Exec("sh", "-c", command)
+	if err != nil {
+		return &VerificationError{command, err}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package tasks
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/transcovo/foreachrepo/git"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func verifyDir(files map[string]string) string {
+	dir, mkdir_err := ioutil.TempDir("", "")
+	if mkdir_err != nil {
+		panic(mkdir_err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			panic(err)
+		}
+	}
+	return dir
+}
+
+func TestVerifyCommand(t *testing.T) {
+	npmDir := verifyDir(map[string]string{"package.json": `{"scripts": {"test": "mocha"}}`, "Makefile": "test:\n\tgo test\n"})
+	defer os.RemoveAll(npmDir)
+	defaultNpmDir := verifyDir(map[string]string{"package.json": `{"scripts": {"test": "echo \"Error: no test specified\" && exit 1"}}`})
+	defer os.RemoveAll(defaultNpmDir)
+	makeDir := verifyDir(map[string]string{"Makefile": "build:\n\tgo build\n\ntest: build\n\tgo test\n"})
+	defer os.RemoveAll(makeDir)
+	emptyDir := verifyDir(map[string]string{"Makefile": "build:\n\tgo build\n"})
+	defer os.RemoveAll(emptyDir)
+
+	assert.Equal(t, "npm test", VerifyCommand(npmDir, VERIFY_AUTO))
+	assert.Equal(t, "", VerifyCommand(defaultNpmDir, VERIFY_AUTO))
+	assert.Equal(t, "make test", VerifyCommand(makeDir, VERIFY_AUTO))
+	assert.Equal(t, "", VerifyCommand(emptyDir, VERIFY_AUTO))
+	assert.Equal(t, "./check.sh", VerifyCommand(emptyDir, "./check.sh"))
+}
+
+func TestVerify(t *testing.T) {
+	dir := verifyDir(map[string]string{})
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, Verify(context.Background(), dir, "true"))
+
+	err := Verify(context.Background(), dir, "echo '1 failing test'; exit 1")
+	verificationErr, ok := err.(*VerificationError)
+	assert.True(t, ok)
+	assert.Equal(t, "1 failing test", verificationErr.Cause.(*git.GitError).Output)
+}
@@ -0,0 +1,200 @@
+package vfs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FS is the view tasks have of the files of a repo: a clone on disk, or files fetched through an API. Names are
+// slash separated and relative to the root of the repo, as with io/fs. Reading or removing a missing file fails
+// with an error satisfying os.IsNotExist.
+type FS interface {
+	ReadFile(name string) ([]byte, error)
+	// WriteFile creates the parent directories as needed
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	Remove(name string) error
+	// List returns the names of all the files, sorted, the .git directory left out
+	List() ([]string, error)
+	// Dir is the directory of the clone, empty when the files aren't on disk
+	Dir() string
+}
+
+// OS gives access to the files of a clone
+type OS struct {
+	Root string
+}
+
+func (O *OS) path(name string) string {
+	return filepath.Join(O.Root, filepath.FromSlash(name))
+}
+
+func (O *OS) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(O.path(name))
+}
+
+func (O *OS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(O.path(name)), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(O.path(name), data, perm)
+}
+
+func (O *OS) Remove(name string) error {
+	return os.Remove(O.path(name))
+}
+
+func (O *OS) List() ([]string, error) {
+	names := []string{}
+	err := filepath.Walk(O.Root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if info.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(O.Root, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(name))
+		return nil
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+func (O *OS) Dir() string {
+	return O.Root
+}
+
+// Memory holds files in memory, recording the ones written or removed
+type Memory struct {
+	files    map[string][]byte
+	missing  map[string]bool
+	changed  map[string]bool
+	// the content the files had before any change, the ones missing then being absent
+	original map[string][]byte
+	absent   map[string]bool
+	// Load fetches the files not in memory yet, returning an error satisfying os.IsNotExist for the missing ones.
+	// Files are only looked up in memory when nil.
+	Load    func(name string) ([]byte, error)
+}
+
+func NewMemory(files map[string][]byte) *Memory {
+	memory := &Memory{
+		files: map[string][]byte{},
+		missing: map[string]bool{},
+		changed: map[string]bool{},
+		original: map[string][]byte{},
+		absent: map[string]bool{},
+	}
+	for name, data := range files {
+		memory.files[name] = data
+		memory.original[name] = data
+	}
+	return memory
+}
+
+func notExist(name string) error {
+	return &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+}
+
+func (M *Memory) ReadFile(name string) ([]byte, error) {
+	if data, ok := M.files[name]; ok {
+		return append([]byte{}, data...), nil
+	}
+	if M.Load == nil || M.missing[name] {
+		return nil, notExist(name)
+	}
+	data, err := M.Load(name)
+	if os.IsNotExist(err) {
+		M.missing[name] = true
+		M.absent[name] = true
+	}
+	if err != nil {
+		return nil, err
+	}
+	M.files[name] = data
+	M.original[name] = data
+	return append([]byte{}, data...), nil
+}
+
+func (M *Memory) WriteFile(name string, data []byte, perm os.FileMode) error {
+	M.files[name] = append([]byte{}, data...)
+	M.changed[name] = true
+	delete(M.missing, name)
+	return nil
+}
+
+func (M *Memory) Remove(name string) error {
+	if _, err := M.ReadFile(name); err != nil {
+		return err
+	}
+	delete(M.files, name)
+	M.missing[name] = true
+	M.changed[name] = true
+	return nil
+}
+
+// List returns the names of the files in memory, the ones Load could fetch being unknown until read
+func (M *Memory) List() ([]string, error) {
+	names := []string{}
+	for name := range M.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (M *Memory) Dir() string {
+	return ""
+}
+
+// originalOf returns the content a file had before any change, loading it when it was written without being read
+func (M *Memory) originalOf(name string) ([]byte, bool) {
+	if data, ok := M.original[name]; ok {
+		return data, true
+	}
+	if M.absent[name] || M.Load == nil {
+		return nil, false
+	}
+	data, err := M.Load(name)
+	if err != nil {
+		// unknown, the file is reported as changed
+		M.absent[name] = os.IsNotExist(err)
+		return nil, false
+	}
+	M.original[name] = data
+	return data, true
+}
+
+// ChangedFiles lists the files written or removed, sorted, the ones left with their original content excepted
+func (M *Memory) ChangedFiles() ([]string, error) {
+	names := []string{}
+	for name := range M.Changes() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Changes returns the content of the files written by name, nil for the removed ones. The files written with their
+// original content, or created then removed, are left out.
+func (M *Memory) Changes() map[string][]byte {
+	changes := map[string][]byte{}
+	for name := range M.changed {
+		data, exists := M.files[name]
+		original, existed := M.originalOf(name)
+		if exists == existed && bytes.Equal(data, original) {
+			continue
+		}
+		changes[name] = data
+	}
+	return changes
+}
@@ -0,0 +1,135 @@
+package vfs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOS(t *testing.T) {
+	dir, mkdir_err := ioutil.TempDir("", "")
+	if mkdir_err != nil {
+		panic(mkdir_err)
+	}
+	defer os.RemoveAll(dir)
+	files := &OS{Root: dir}
+
+	_, err := files.ReadFile("package.json")
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Nil(t, files.WriteFile(".github/dependabot.yml", []byte("version: 2\n"), 0644))
+	data, err := ioutil.ReadFile(filepath.Join(dir, ".github", "dependabot.yml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "version: 2\n", string(data))
+	assert.Equal(t, dir, files.Dir())
+}
+
+func TestMemory(t *testing.T) {
+	loads := []string{}
+	files := NewMemory(map[string][]byte{"package.json": []byte("{}")})
+	files.Load = func(name string) ([]byte, error) {
+		loads = append(loads, name)
+		if name == "CODEOWNERS" {
+			return []byte("* @org/team"), nil
+		}
+		if name == "broken" {
+			return nil, errors.New("API error")
+		}
+		return nil, notExist(name)
+	}
+
+	data, err := files.ReadFile("package.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "{}", string(data))
+
+	data, err = files.ReadFile("CODEOWNERS")
+	assert.Nil(t, err)
+	assert.Equal(t, "* @org/team", string(data))
+	files.ReadFile("CODEOWNERS")
+
+	_, err = files.ReadFile(".github/CODEOWNERS")
+	assert.True(t, os.IsNotExist(err))
+	files.ReadFile(".github/CODEOWNERS")
+
+	_, err = files.ReadFile("broken")
+	assert.Equal(t, "API error", err.Error())
+	assert.Equal(t, []string{"CODEOWNERS", ".github/CODEOWNERS", "broken"}, loads, "files are loaded once")
+
+	files.WriteFile("package.json", []byte(`{"name": "repo1"}`), 0644)
+	files.WriteFile(".github/CODEOWNERS", []byte("* @org/other-team"), 0644)
+	changed, _ := files.ChangedFiles()
+	assert.Equal(t, []string{".github/CODEOWNERS", "package.json"}, changed)
+	assert.Equal(t, map[string][]byte{
+		"package.json": []byte(`{"name": "repo1"}`),
+		".github/CODEOWNERS": []byte("* @org/other-team"),
+	}, files.Changes())
+	assert.Equal(t, "", files.Dir())
+}
+
+func TestOSListAndRemove(t *testing.T) {
+	dir, mkdir_err := ioutil.TempDir("", "")
+	if mkdir_err != nil {
+		panic(mkdir_err)
+	}
+	defer os.RemoveAll(dir)
+	files := &OS{Root: dir}
+	files.WriteFile(".git/HEAD", []byte("ref: refs/heads/master\n"), 0644)
+	files.WriteFile("package.json", []byte("{}"), 0644)
+	files.WriteFile("src/index.js", []byte(""), 0644)
+	files.WriteFile(".eslintrc.json", []byte("{}"), 0644)
+
+	names, err := files.List()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{".eslintrc.json", "package.json", "src/index.js"}, names)
+
+	assert.Nil(t, files.Remove(".eslintrc.json"))
+	assert.True(t, os.IsNotExist(files.Remove(".eslintrc.json")))
+	names, _ = files.List()
+	assert.Equal(t, []string{"package.json", "src/index.js"}, names)
+}
+
+func TestMemoryUnchanged(t *testing.T) {
+	files := NewMemory(map[string][]byte{"package.json": []byte("{}")})
+	files.Load = func(name string) ([]byte, error) {
+		if name == "LICENSE" {
+			return []byte("MIT"), nil
+		}
+		return nil, notExist(name)
+	}
+
+	files.WriteFile("package.json", []byte(`{"name": "repo1"}`), 0644)
+	files.WriteFile("package.json", []byte("{}"), 0644)
+	files.WriteFile("LICENSE", []byte("MIT"), 0644)
+	files.WriteFile("tmp.txt", []byte("temporary"), 0644)
+	files.Remove("tmp.txt")
+	files.WriteFile("README.md", []byte("# repo1"), 0644)
+
+	changed, _ := files.ChangedFiles()
+	assert.Equal(t, []string{"README.md"}, changed)
+	assert.Equal(t, map[string][]byte{"README.md": []byte("# repo1")}, files.Changes())
+}
+
+func TestMemoryListAndRemove(t *testing.T) {
+	files := NewMemory(map[string][]byte{"package.json": []byte("{}"), "src/index.js": []byte("")})
+	files.Load = func(name string) ([]byte, error) {
+		if name == ".eslintrc.json" {
+			return []byte("{}"), nil
+		}
+		return nil, notExist(name)
+	}
+
+	assert.Nil(t, files.Remove(".eslintrc.json"))
+	assert.Nil(t, files.Remove("src/index.js"))
+	assert.True(t, os.IsNotExist(files.Remove("src/index.js")))
+	_, err := files.ReadFile(".eslintrc.json")
+	assert.True(t, os.IsNotExist(err))
+
+	names, _ := files.List()
+	assert.Equal(t, []string{"package.json"}, names)
+	changed, _ := files.ChangedFiles()
+	assert.Equal(t, []string{".eslintrc.json", "src/index.js"}, changed)
+	assert.Equal(t, map[string][]byte{".eslintrc.json": nil, "src/index.js": nil}, files.Changes())
+}